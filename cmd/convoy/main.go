@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+	"github.com/joho/godotenv"
+
+	"tcblabs.net/sequentialconvoy/pkg/convoy"
+)
+
+// processStep is the sample handler. It shows the parts of a message a handler typically uses and how the returned
+// error settles the message: nil completes it, ErrDeadLetter moves it to the dead-letter queue and a context error,
+// e.g. from the handler timeout, abandons it for redelivery.
+func processStep(ctx context.Context, msg *servicebus.Message) error {
+	var seq int64
+	var enqueued time.Time
+	if sp := msg.SystemProperties; sp != nil {
+		if sp.SequenceNumber != nil {
+			seq = *sp.SequenceNumber
+		}
+		if sp.EnqueuedTime != nil {
+			enqueued = *sp.EnqueuedTime
+		}
+	}
+	fmt.Printf("  [%s] Session: %s Sequence: %d Enqueued: %v Delivery: %d Properties: %v Data: %s\n",
+		convoy.CorrelationID(ctx), *msg.SessionID, seq, enqueued.Format(time.RFC3339), msg.DeliveryCount, msg.UserProperties, string(msg.Data))
+
+	if len(msg.Data) == 0 {
+		return &convoy.ErrDeadLetter{Reason: "EmptyBody", Description: "step message has no body"}
+	}
+
+	// Processing of message simulated through delay
+	select {
+	case <-time.After(5 * time.Second):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func main() {
+	os.Exit(run())
+}
+
+// run runs the sample until it is interrupted with SIGINT or SIGTERM or the convoy stops on an error, and returns the
+// exit code of the process. On a signal the convoy stops accepting sessions, drains the current ones, bounded by
+// HARD_SHUTDOWN_TIMEOUT if set, and closes its clients. A second signal kills the process right away.
+func run() int {
+	// Read env variables from .env file if it exists
+	if err := loadEnvFile(".env"); err != nil {
+		fmt.Printf("FATAL: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := convoy.LoadConfig("")
+	if err != nil {
+		fmt.Printf("FATAL: %v\n", err)
+		return 1
+	}
+
+	opts := cfg.Options()
+	if cfg.AuditLogFile != "" {
+		f, err := os.OpenFile(cfg.AuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer f.Close()
+		opts = append(opts, convoy.WithAuditSink(convoy.NewJSONAuditSink(f)))
+	}
+
+	p, err := convoy.NewProcessor(cfg.ConnectionString, cfg.QueueName, convoy.HandlerFunc(processStep), opts...)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if cfg.HealthAddr != "" {
+		http.Handle("/healthz", p.HealthHandler())
+		http.Handle("/debug/convoy", p.DebugHandler())
+		go func() {
+			fmt.Println(http.ListenAndServe(cfg.HealthAddr, nil))
+		}()
+	}
+
+	if err = p.Start(ctx); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	select {
+	case <-ctx.Done():
+		// Restore the default handling so that a second signal terminates the process
+		stop()
+		fmt.Println("🛑 Received shutdown signal. Draining sessions.")
+	case <-p.Done():
+	}
+
+	if err = p.Stop(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// loadEnvFile sets the variables of the env file at path that are not already set in the environment. A missing file
+// is not an error. Only the sample loads env files: the convoy package is configured through New and its options, or
+// LoadConfig, which reads the process environment.
+func loadEnvFile(path string) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := godotenv.Load(path); err != nil {
+		return fmt.Errorf("load env file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package convoy
+
+// AcceptDecision is the reaction to a failure to accept a session, returned by the hook set with WithOnAcceptError
+type AcceptDecision int
+
+const (
+	// AcceptDefault reacts as the convoy does without a hook: it retries after timeouts and throttling, reconnects
+	// after connection failures and stops on any other error.
+	AcceptDefault AcceptDecision = iota
+	// AcceptRetry tries to accept a session again right away.
+	AcceptRetry
+	// AcceptBackoff tries to accept a session again after a short backoff.
+	AcceptBackoff
+	// AcceptStop stops the convoy, which returns the error from Run.
+	AcceptStop
+)
+
+// WithOnAcceptError calls fn when accepting a session fails for a reason other than no session becoming available in
+// time, and reacts as fn decides. Errors that occur while a session is processed are not passed to fn.
+func WithOnAcceptError(fn func(err error) AcceptDecision) Option {
+	return func(c *Convoy) {
+		c.onAcceptError = fn
+	}
+}
+
+// acceptDecision asks the hook how to react to err, if a session could not be accepted
+func (c *Convoy) acceptDecision(sess *StepSessionHandler, err error) AcceptDecision {
+	if c.onAcceptError == nil || sess.session() != nil || c.classify(err) == ErrorTimeout {
+		return AcceptDefault
+	}
+	return c.onAcceptError(err)
+}
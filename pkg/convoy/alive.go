@@ -0,0 +1,44 @@
+package convoy
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// WithHeartbeatLog logs a line every interval while the convoy holds no session, stating that it is alive and when
+// it last processed a message, so that monitoring based on logs alone can tell an idle convoy from a hung one. No
+// heartbeat is logged while sessions are being processed, since those log on their own.
+func WithHeartbeatLog(interval time.Duration) Option {
+	return func(c *Convoy) {
+		c.heartbeatLog = interval
+	}
+}
+
+// markActivity records that a message was received
+func (c *Convoy) markActivity() {
+	atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+}
+
+// logHeartbeats logs the heartbeat while the convoy is idle until ctx is done
+func (c *Convoy) logHeartbeats(ctx context.Context) {
+	ticker := time.NewTicker(c.heartbeatLog)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		if atomic.LoadInt64(&c.activeSessions) > 0 {
+			continue
+		}
+		last := "never"
+		if ns := atomic.LoadInt64(&c.lastActivity); ns > 0 {
+			last = time.Unix(0, ns).Format(time.RFC3339)
+		}
+		c.logf("💓 Convoy alive, waiting for sessions. Last activity at %s.", last)
+	}
+}
@@ -0,0 +1,82 @@
+package convoy
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// maxTrackedAttempts bounds the messages whose attempts are counted in memory. Once exceeded the counts start over.
+const maxTrackedAttempts = 10000
+
+type attemptKey struct{}
+
+// Attempt tells a handler whether and how often the message in its context was attempted before
+type Attempt struct {
+	// DeliveryCount is the broker's count of deliveries of the message, including this one. It survives restarts and
+	// counts deliveries to any receiver, also those that never reached the handler, e.g. because a lock expired. The
+	// broker dead-letters the message once it exceeds the queue's maximum delivery count.
+	DeliveryCount uint32
+
+	// Handled counts the invocations of the handler for the message by this convoy, including this one. It is kept in
+	// memory only, so it starts over after a restart and on another replica, and it only counts deliveries that reached
+	// the handler.
+	Handled int
+
+	// RetryQueuePasses counts how often the message went through the retry queue, see WithRetryQueue
+	RetryQueuePasses int
+}
+
+// IsRetry reports whether the message was delivered or handled before
+func (a Attempt) IsRetry() bool {
+	return a.DeliveryCount > 1 || a.Handled > 1 || a.RetryQueuePasses > 0
+}
+
+// AttemptOf returns the attempt of the message handled with ctx. Outside of a handler it returns the zero Attempt.
+func AttemptOf(ctx context.Context) Attempt {
+	a, _ := ctx.Value(attemptKey{}).(Attempt)
+	return a
+}
+
+// attemptCounter counts handler invocations per message
+type attemptCounter struct {
+	sync.Mutex
+	handled map[string]int
+}
+
+// next counts an invocation for msg and returns the number of invocations so far
+func (a *attemptCounter) next(msg *servicebus.Message) int {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.handled == nil || len(a.handled) >= maxTrackedAttempts {
+		a.handled = make(map[string]int)
+	}
+	key := attemptKeyOf(msg)
+	a.handled[key]++
+	return a.handled[key]
+}
+
+// forget drops the count of a message that left the session
+func (a *attemptCounter) forget(msg *servicebus.Message) {
+	a.Lock()
+	delete(a.handled, attemptKeyOf(msg))
+	a.Unlock()
+}
+
+// attemptKeyOf identifies msg by its session and sequence number
+func attemptKeyOf(msg *servicebus.Message) string {
+	return sessionIDOf(msg) + "/" + strconv.FormatInt(sequenceOf(msg), 10)
+}
+
+// withAttempt counts the invocation of the handler for msg and stores its attempt in ctx
+func (c *Convoy) withAttempt(ctx context.Context, msg *servicebus.Message) context.Context {
+	passes, _ := msg.UserProperties[retryCountProperty].(int64)
+	return context.WithValue(ctx, attemptKey{}, Attempt{
+		DeliveryCount:    msg.DeliveryCount,
+		Handled:          c.attempts.next(msg),
+		RetryQueuePasses: int(passes),
+	})
+}
@@ -0,0 +1,48 @@
+package convoy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestAttemptCountsRedeliveries(t *testing.T) {
+	broker := newFakeBroker()
+	msgs := broker.add("a", "1", "2")
+	// The second message was delivered before, to a receiver that lost its lock before handling it
+	msgs[1].DeliveryCount = 2
+
+	var attempts []Attempt
+	c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+		a := AttemptOf(ctx)
+		attempts = append(attempts, a)
+		if msg.ID == "a-1" && a.Handled < 3 {
+			return ErrAbandon
+		}
+		return nil
+	})
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	want := []Attempt{
+		{DeliveryCount: 1, Handled: 1},
+		{DeliveryCount: 2, Handled: 2},
+		{DeliveryCount: 3, Handled: 3},
+		{DeliveryCount: 2, Handled: 1},
+	}
+	if !reflect.DeepEqual(attempts, want) {
+		t.Errorf("attempts %+v, want %+v", attempts, want)
+	}
+	for i, a := range attempts {
+		if retry := i > 0; a.IsRetry() != retry {
+			t.Errorf("attempt %+v: IsRetry() = %v, want %v", a, a.IsRetry(), retry)
+		}
+	}
+
+	if a := AttemptOf(context.Background()); a != (Attempt{}) {
+		t.Errorf("attempt outside of a handler %+v, want the zero Attempt", a)
+	}
+}
@@ -0,0 +1,169 @@
+package convoy
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// Outcome is the settlement applied to a message
+type Outcome string
+
+const (
+	OutcomeCompleted    Outcome = "completed"
+	OutcomeAbandoned    Outcome = "abandoned"
+	OutcomeDeadLettered Outcome = "deadlettered"
+	OutcomeDeferred     Outcome = "deferred"
+
+	// OutcomeReleased leaves the message unsettled for the broker to redeliver once the session is released
+	OutcomeReleased Outcome = "released"
+)
+
+const auditBufferSize = 1024
+
+// AuditRecord describes a single settled message
+type AuditRecord struct {
+	SessionID      string    `json:"sessionId"`
+	MessageID      string    `json:"messageId"`
+	SequenceNumber int64     `json:"sequenceNumber"`
+	EnqueuedAt     time.Time `json:"enqueuedAt"`
+	ProcessedAt    time.Time `json:"processedAt"`
+	Outcome        Outcome   `json:"outcome"`
+}
+
+// AuditSink receives a record for every message settled by the convoy. Record is called on the processing path and
+// should return quickly. Close flushes any buffered records.
+type AuditSink interface {
+	Record(rec AuditRecord)
+	Close() error
+}
+
+func newAuditRecord(msg *servicebus.Message, outcome Outcome) AuditRecord {
+	rec := AuditRecord{
+		MessageID:   msg.ID,
+		ProcessedAt: time.Now().UTC(),
+		Outcome:     outcome,
+	}
+	if msg.SessionID != nil {
+		rec.SessionID = *msg.SessionID
+	}
+	if sp := msg.SystemProperties; sp != nil {
+		if sp.SequenceNumber != nil {
+			rec.SequenceNumber = *sp.SequenceNumber
+		}
+		if sp.EnqueuedTime != nil {
+			rec.EnqueuedAt = sp.EnqueuedTime.UTC()
+		}
+	}
+
+	return rec
+}
+
+// streamAuditSink writes records to a writer from a background goroutine so that the processing path only pays
+// for a channel send. The channel is sized to absorb bursts; once full, Record blocks rather than drop a record.
+// Records sent to a closed sink, e.g. by a settlement still in flight during shutdown, are dropped.
+type streamAuditSink struct {
+	records chan AuditRecord
+	done    chan struct{}
+	err     error
+
+	// mu guards closed against the close of records; Record holds it for reading while it sends
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewJSONAuditSink creates an audit sink that appends one JSON document per record to w
+func NewJSONAuditSink(w io.Writer) AuditSink {
+	return newStreamAuditSink(w, func(bw *bufio.Writer) func(AuditRecord) error {
+		enc := json.NewEncoder(bw)
+		return func(rec AuditRecord) error {
+			return enc.Encode(rec)
+		}
+	})
+}
+
+// NewCSVAuditSink creates an audit sink that appends one CSV row per record to w, preceded by a header row
+func NewCSVAuditSink(w io.Writer) AuditSink {
+	return newStreamAuditSink(w, func(bw *bufio.Writer) func(AuditRecord) error {
+		cw := csv.NewWriter(bw)
+		header := false
+		return func(rec AuditRecord) error {
+			if !header {
+				header = true
+				if err := cw.Write([]string{"sessionId", "messageId", "sequenceNumber", "enqueuedAt", "processedAt", "outcome"}); err != nil {
+					return err
+				}
+			}
+			cw.Write([]string{
+				rec.SessionID,
+				rec.MessageID,
+				strconv.FormatInt(rec.SequenceNumber, 10),
+				rec.EnqueuedAt.Format(time.RFC3339Nano),
+				rec.ProcessedAt.Format(time.RFC3339Nano),
+				string(rec.Outcome),
+			})
+			cw.Flush()
+			return cw.Error()
+		}
+	})
+}
+
+func newStreamAuditSink(w io.Writer, encoder func(*bufio.Writer) func(AuditRecord) error) *streamAuditSink {
+	s := &streamAuditSink{
+		records: make(chan AuditRecord, auditBufferSize),
+		done:    make(chan struct{}),
+	}
+
+	bw := bufio.NewWriter(w)
+	encode := encoder(bw)
+	go func() {
+		defer close(s.done)
+		for rec := range s.records {
+			if err := encode(rec); err != nil && s.err == nil {
+				s.err = err
+			}
+
+			// Flush once the backlog is written so records reach w without waiting for Close
+			if len(s.records) == 0 {
+				if err := bw.Flush(); err != nil && s.err == nil {
+					s.err = err
+				}
+			}
+		}
+		if err := bw.Flush(); err != nil && s.err == nil {
+			s.err = err
+		}
+	}()
+
+	return s
+}
+
+// Record queues rec for writing unless the sink is closed
+func (s *streamAuditSink) Record(rec AuditRecord) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+	s.records <- rec
+}
+
+// Close writes all queued records and returns the first write error encountered
+func (s *streamAuditSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.records)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return s.err
+}
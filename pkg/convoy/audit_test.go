@@ -0,0 +1,127 @@
+package convoy
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testAuditRecords = []AuditRecord{
+	{
+		SessionID:      "a",
+		MessageID:      "a-1",
+		SequenceNumber: 1,
+		EnqueuedAt:     time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+		ProcessedAt:    time.Date(2021, 3, 1, 10, 0, 1, 500, time.UTC),
+		Outcome:        OutcomeCompleted,
+	},
+	{
+		SessionID:      "a",
+		MessageID:      "a-2",
+		SequenceNumber: 2,
+		EnqueuedAt:     time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC),
+		ProcessedAt:    time.Date(2021, 3, 1, 10, 0, 2, 0, time.UTC),
+		Outcome:        OutcomeDeadLettered,
+	},
+}
+
+func TestJSONAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONAuditSink(&buf)
+	for _, rec := range testAuditRecords {
+		sink.Record(rec)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var got []AuditRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec AuditRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decode record: %v", err)
+		}
+		got = append(got, rec)
+	}
+	if !reflect.DeepEqual(got, testAuditRecords) {
+		t.Errorf("records %+v, want %+v", got, testAuditRecords)
+	}
+}
+
+func TestCSVAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewCSVAuditSink(&buf)
+	for _, rec := range testAuditRecords {
+		sink.Record(rec)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	want := [][]string{
+		{"sessionId", "messageId", "sequenceNumber", "enqueuedAt", "processedAt", "outcome"},
+		{"a", "a-1", "1", "2021-03-01T10:00:00Z", "2021-03-01T10:00:01.0000005Z", "completed"},
+		{"a", "a-2", "2", "2021-03-01T10:00:00Z", "2021-03-01T10:00:02Z", "deadlettered"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows %q, want %q", rows, want)
+	}
+}
+
+func TestAuditSinkRecordAfterClose(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONAuditSink(&buf)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	sink.Record(testAuditRecords[0])
+	if err := sink.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("record written after Close: %q", buf.String())
+	}
+}
+
+func TestAuditSinkRecordDuringClose(t *testing.T) {
+	sink := NewCSVAuditSink(&bytes.Buffer{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sink.Record(testAuditRecords[0])
+			}
+		}()
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	wg.Wait()
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestAuditSinkReportsWriteError(t *testing.T) {
+	sink := NewJSONAuditSink(failingWriter{})
+	sink.Record(testAuditRecords[0])
+	if err := sink.Close(); err == nil {
+		t.Error("Close = nil, want the write error")
+	}
+}
@@ -0,0 +1,84 @@
+package convoy
+
+import (
+	"sync/atomic"
+)
+
+// Hysteresis of the autoscaler: the convoy adds a receive loop after scaleUpAfter consecutive sessions were accepted
+// without any loop timing out, and a loop retires after scaleDownAfter consecutive accepts of its own timed out
+const (
+	scaleUpAfter   = 3
+	scaleDownAfter = 3
+)
+
+// WithAutoScale adjusts the number of concurrent sessions between min and max to the activity on the queue, instead of
+// the fixed number set with WithConcurrentSessions. The convoy starts with min receive loops and adds one whenever
+// sessions keep being accepted, as a sign that more are waiting, and retires one whenever a loop repeatedly finds no
+// session to accept, so idle queues do not hold max receivers. The current number is reported as the
+// convoy_concurrent_sessions gauge.
+func WithAutoScale(min, max int) Option {
+	return func(c *Convoy) {
+		c.autoScale = &autoScaler{
+			min: int64(min),
+			max: int64(max),
+			up:  make(chan struct{}, 1),
+		}
+	}
+}
+
+// autoScaler decides when to add or retire receive loops
+type autoScaler struct {
+	min, max int64
+	busy     int64
+	up       chan struct{}
+}
+
+// accepted counts a session accepted by any loop and requests another loop when enough sessions were accepted in a row
+func (a *autoScaler) accepted() {
+	if atomic.AddInt64(&a.busy, 1) < scaleUpAfter {
+		return
+	}
+	atomic.StoreInt64(&a.busy, 0)
+	select {
+	case a.up <- struct{}{}:
+	default:
+	}
+}
+
+// timedOut resets the run of accepted sessions
+func (a *autoScaler) timedOut() {
+	atomic.StoreInt64(&a.busy, 0)
+}
+
+// limit caps the number of loops, e.g. at the number the namespace's quota permits
+func (a *autoScaler) limit(n int64) {
+	atomic.StoreInt64(&a.max, n)
+}
+
+// scaleDown retires the calling loop after consecutive timeouts unless the convoy is at its minimum and reports whether
+// it did
+func (c *Convoy) scaleDown(stats *runStats, timeouts int) bool {
+	a := c.autoScale
+	if a == nil || timeouts < scaleDownAfter {
+		return false
+	}
+
+	left, ok := stats.retireLoopAbove(a.min)
+	if !ok {
+		return false
+	}
+	c.logf("📉 No sessions available. Scaling down to %d concurrent sessions.", left)
+	c.metrics.SetGauge(metricConcurrency, float64(left))
+	return true
+}
+
+// scaleUp adds a loop unless the convoy is at its maximum and reports whether it did
+func (c *Convoy) scaleUp(stats *runStats) bool {
+	n, ok := stats.addLoopBelow(atomic.LoadInt64(&c.autoScale.max))
+	if !ok {
+		return false
+	}
+	c.logf("📈 Sessions keep arriving. Scaling up to %d concurrent sessions.", n)
+	c.metrics.SetGauge(metricConcurrency, float64(n))
+	return true
+}
@@ -0,0 +1,122 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// benchSessionSize is the number of messages per session in the benchmarks
+const benchSessionSize = 100
+
+// countingMetrics counts the metric updates it receives
+type countingMetrics struct {
+	updates int64
+}
+
+func (m *countingMetrics) IncCounter(string)        { atomic.AddInt64(&m.updates, 1) }
+func (m *countingMetrics) SetGauge(string, float64) { atomic.AddInt64(&m.updates, 1) }
+func (m *countingMetrics) Observe(string, float64)  { atomic.AddInt64(&m.updates, 1) }
+
+// countingSink counts the events it receives
+type countingSink struct {
+	events int64
+}
+
+func (s *countingSink) Emit(Event) { atomic.AddInt64(&s.events, 1) }
+
+// benchTracing traces every message: a log line when its processing starts and one when it is settled, and the
+// lifecycle events of sessions and messages. The convoy has no tracer of its own; these are the per-message traces it
+// leaves.
+func benchTracing() []Option {
+	return []Option{WithProcessingLog(), WithEventSink(&countingSink{})}
+}
+
+// benchmarkConvoy drains b.N messages, spread over sessions of benchSessionSize messages, through a convoy with a
+// handler that completes every message. One operation is one message, so allocs/op are the allocations per message.
+func benchmarkConvoy(b *testing.B, opts ...Option) {
+	benchmarkBroker(b, newFakeBroker(), nopHandler, opts...)
+}
+
+// benchmarkBroker is benchmarkConvoy on broker with handler
+func benchmarkBroker(b *testing.B, broker *fakeBroker, handler HandlerFunc, opts ...Option) {
+	for i := 0; i < b.N; i += benchSessionSize {
+		bodies := make([]string, 0, benchSessionSize)
+		for j := i; j < b.N && j < i+benchSessionSize; j++ {
+			bodies = append(bodies, "step")
+		}
+		broker.add(fmt.Sprintf("session-%d", i/benchSessionSize), bodies...)
+	}
+	c := newTestConvoy(b, broker, handler, append([]Option{WithEmptyAccepts(1)}, opts...)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	summary, err := c.RunOnce(context.Background())
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	if err != nil {
+		b.Fatalf("RunOnce: %v", err)
+	}
+	if summary.Messages != int64(b.N) {
+		b.Fatalf("processed %d messages, want %d", summary.Messages, b.N)
+	}
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "msgs/s")
+}
+
+func BenchmarkConvoySingleSession(b *testing.B) {
+	benchmarkConvoy(b)
+}
+
+func BenchmarkConvoySingleSessionMetrics(b *testing.B) {
+	benchmarkConvoy(b, WithMetrics(&countingMetrics{}))
+}
+
+func BenchmarkConvoySingleSessionTracing(b *testing.B) {
+	benchmarkConvoy(b, benchTracing()...)
+}
+
+func BenchmarkConvoyConcurrentSessions(b *testing.B) {
+	benchmarkConvoy(b, WithConcurrentSessions(8))
+}
+
+func BenchmarkConvoyConcurrentSessionsMetrics(b *testing.B) {
+	benchmarkConvoy(b, WithConcurrentSessions(8), WithMetrics(&countingMetrics{}))
+}
+
+func BenchmarkConvoyConcurrentSessionsTracing(b *testing.B) {
+	benchmarkConvoy(b, append(benchTracing(), WithConcurrentSessions(8))...)
+}
+
+// benchDecodeCost and benchSettleLatency are the time taken to decode a message and to settle it in the decode
+// benchmarks, comparable so that pipelining hides one of them
+const (
+	benchDecodeCost    = 200 * time.Microsecond
+	benchSettleLatency = 200 * time.Microsecond
+)
+
+// benchDecode stands in for deserializing and validating the body of msg
+func benchDecode(msg *servicebus.Message) (interface{}, error) {
+	time.Sleep(benchDecodeCost)
+	return msg.Data, nil
+}
+
+func BenchmarkConvoyDecode(b *testing.B) {
+	broker := newFakeBroker()
+	broker.settleLatency = benchSettleLatency
+	benchmarkBroker(b, broker, func(_ context.Context, msg *servicebus.Message) error {
+		_, err := benchDecode(msg)
+		return err
+	})
+}
+
+func BenchmarkConvoyPipelinedDecode(b *testing.B) {
+	broker := newFakeBroker()
+	broker.settleLatency = benchSettleLatency
+	benchmarkBroker(b, broker, nopHandler, WithPipelinedDecode(benchDecode))
+}
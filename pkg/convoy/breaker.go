@@ -0,0 +1,92 @@
+package convoy
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTrackedCircuits bounds the sessions whose failures and open circuits are kept in memory. Once exceeded, circuits
+// whose cooldown has passed are dropped, and if that does not free enough room the failure counts start over.
+const maxTrackedCircuits = 10000
+
+// WithSessionCircuitBreaker stops processing a session after threshold consecutive failures, i.e. abandoned messages
+// or lost locks, for the duration of cooldown. While the circuit of a session is open, the convoy releases the session
+// as soon as it is accepted, without settling its message, and moves on to other sessions. Once the cooldown has
+// passed the session is processed again and a completed message resets its failure count.
+func WithSessionCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Convoy) {
+		c.breaker = &sessionBreaker{
+			threshold: threshold,
+			cooldown:  cooldown,
+			failures:  make(map[string]int),
+			openUntil: make(map[string]time.Time),
+		}
+	}
+}
+
+// sessionBreaker tracks consecutive failures per session ID
+type sessionBreaker struct {
+	sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+// allow reports whether the circuit of the session is closed
+func (b *sessionBreaker) allow(sessionID string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	until, ok := b.openUntil[sessionID]
+	if !ok {
+		return true
+	}
+	if time.Now().Before(until) {
+		return false
+	}
+
+	delete(b.openUntil, sessionID)
+	return true
+}
+
+// success resets the failure count of the session
+func (b *sessionBreaker) success(sessionID string) {
+	b.Lock()
+	delete(b.failures, sessionID)
+	b.Unlock()
+}
+
+// failure counts a failure of the session and reports whether it opened the circuit
+func (b *sessionBreaker) failure(sessionID string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.failures[sessionID]; !ok && len(b.failures) >= maxTrackedCircuits {
+		b.failures = make(map[string]int)
+	}
+	b.failures[sessionID]++
+	if b.failures[sessionID] < b.threshold {
+		return false
+	}
+
+	delete(b.failures, sessionID)
+	if _, ok := b.openUntil[sessionID]; !ok && len(b.openUntil) >= maxTrackedCircuits {
+		b.prune(time.Now())
+	}
+	b.openUntil[sessionID] = time.Now().Add(b.cooldown)
+	return true
+}
+
+// prune drops the circuits whose cooldown passed by now, and all circuits if they are all still open. The caller
+// holds the lock.
+func (b *sessionBreaker) prune(now time.Time) {
+	for id, until := range b.openUntil {
+		if !now.Before(until) {
+			delete(b.openUntil, id)
+		}
+	}
+	if len(b.openUntil) >= maxTrackedCircuits {
+		b.openUntil = make(map[string]time.Time)
+	}
+}
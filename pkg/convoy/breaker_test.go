@@ -0,0 +1,80 @@
+package convoy
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) *sessionBreaker {
+	c := &Convoy{}
+	WithSessionCircuitBreaker(threshold, cooldown)(c)
+	return c.breaker
+}
+
+func TestSessionBreakerOpensAndHalfOpens(t *testing.T) {
+	b := newTestBreaker(2, time.Hour)
+
+	if b.failure("a") {
+		t.Fatal("circuit opened below the threshold")
+	}
+	if !b.allow("a") {
+		t.Fatal("circuit not allowed below the threshold")
+	}
+	if !b.failure("a") {
+		t.Fatal("circuit did not open at the threshold")
+	}
+	if b.allow("a") {
+		t.Error("open circuit allowed during its cooldown")
+	}
+	if !b.allow("b") {
+		t.Error("circuit of another session not allowed")
+	}
+
+	// Once the cooldown has passed the circuit is half-open: the session is processed again, and it takes threshold
+	// further failures to open it again
+	b.openUntil["a"] = time.Now().Add(-time.Second)
+	if !b.allow("a") {
+		t.Fatal("circuit not allowed after its cooldown")
+	}
+	if b.failure("a") {
+		t.Error("half-open circuit reopened below the threshold")
+	}
+	b.success("a")
+	if b.failure("a") {
+		t.Error("failure count not reset by a success")
+	}
+	if !b.failure("a") {
+		t.Error("half-open circuit did not reopen at the threshold")
+	}
+}
+
+func TestSessionBreakerBoundsTrackedSessions(t *testing.T) {
+	b := newTestBreaker(1, time.Hour)
+	for i := 0; i < maxTrackedCircuits; i++ {
+		b.failure(strconv.Itoa(i))
+	}
+	if len(b.openUntil) != maxTrackedCircuits {
+		t.Fatalf("tracking %d open circuits, want %d", len(b.openUntil), maxTrackedCircuits)
+	}
+
+	// Circuits whose cooldown passed are dropped first
+	for i := 0; i < maxTrackedCircuits/2; i++ {
+		b.openUntil[strconv.Itoa(i)] = time.Now().Add(-time.Second)
+	}
+	b.failure("next")
+	if n := len(b.openUntil); n != maxTrackedCircuits/2+1 {
+		t.Errorf("tracking %d open circuits after pruning, want %d", n, maxTrackedCircuits/2+1)
+	}
+	if b.allow(strconv.Itoa(maxTrackedCircuits - 1)) {
+		t.Error("circuit still in its cooldown dropped by pruning")
+	}
+
+	b = newTestBreaker(2, time.Hour)
+	for i := 0; i <= maxTrackedCircuits; i++ {
+		b.failure(strconv.Itoa(i))
+	}
+	if n := len(b.failures); n > maxTrackedCircuits {
+		t.Errorf("tracking failures of %d sessions, want at most %d", n, maxTrackedCircuits)
+	}
+}
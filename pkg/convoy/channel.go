@@ -0,0 +1,78 @@
+package convoy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+type settledKey struct{}
+
+// settleNotifier is called once with the result of the settlement of the message handled with a context
+type settleNotifier struct {
+	once sync.Once
+	fn   func(SettleResult)
+}
+
+// SettleResult is the final settlement of a message: the outcome chosen for it and the error, if any, that prevented
+// the broker from applying it. Err is set e.g. when the lock was lost, in which case the broker redelivers the message.
+type SettleResult struct {
+	Outcome Outcome
+	Err     error
+}
+
+// Delivery is a message handed to a pull based consumer, see ChannelHandler
+type Delivery struct {
+	Ctx     context.Context
+	Message *servicebus.Message
+
+	decision chan error
+	result   chan SettleResult
+}
+
+// Settle hands the decision for the message to the convoy, as a HandlerFunc would return it: nil completes the
+// message and the sentinel errors select the other outcomes. It waits until the message is settled with the broker
+// and returns the result. Settle must be called exactly once per delivery.
+func (d *Delivery) Settle(decision error) SettleResult {
+	d.decision <- decision
+	return <-d.result
+}
+
+// ChannelHandler returns a HandlerFunc that sends each message to deliveries for a consumer to process and settle.
+// Ordering is the same as with any other handler: the next message of a session is only delivered after the consumer
+// settled the current one and its Settle returned, so settlements of a session are confirmed in session order.
+// Messages of different sessions may be pending at the same time with concurrent sessions.
+func ChannelHandler(deliveries chan<- *Delivery) HandlerFunc {
+	return func(ctx context.Context, msg *servicebus.Message) error {
+		d := &Delivery{
+			Ctx:      ctx,
+			Message:  msg,
+			decision: make(chan error, 1),
+			result:   make(chan SettleResult, 1),
+		}
+		if n, ok := ctx.Value(settledKey{}).(*settleNotifier); ok {
+			n.fn = func(r SettleResult) { d.result <- r }
+		}
+
+		select {
+		case deliveries <- d:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case err := <-d.decision:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// notifySettled reports the settlement of the message handled with ctx to its notifier, if one was set
+func notifySettled(ctx context.Context, outcome Outcome, err error) {
+	if n, ok := ctx.Value(settledKey{}).(*settleNotifier); ok && n.fn != nil {
+		n.once.Do(func() { n.fn(SettleResult{Outcome: outcome, Err: err}) })
+	}
+}
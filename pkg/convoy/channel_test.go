@@ -0,0 +1,66 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestDeliverySettleReturnsWhenEarlierSettlementFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision error
+	}{
+		{name: "completion queued behind failure", decision: nil},
+		{name: "abandon waiting for failure", decision: ErrAbandon},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker()
+			broker.add("a", "1", "2", "3")
+			failure := errors.New("settlement rejected")
+			second := make(chan struct{})
+			broker.settleErr = func(msg *servicebus.Message) error {
+				if msg.ID != "a-1" {
+					return nil
+				}
+				// The completion of the first message fails once the second is being handled
+				<-second
+				return failure
+			}
+
+			deliveries := make(chan *Delivery)
+			c := newTestConvoy(t, broker, ChannelHandler(deliveries), WithAsyncSettlement(1), WithSettleRetry(1, time.Millisecond))
+			results := make(chan SettleResult, 2)
+			go func() {
+				for i := 0; i < 2; i++ {
+					d := <-deliveries
+					decision := error(nil)
+					if i == 1 {
+						close(second)
+						decision = tt.decision
+					}
+					go func() { results <- d.Settle(decision) }()
+				}
+			}()
+
+			if _, err := c.RunOnce(context.Background()); !errors.Is(err, failure) {
+				t.Fatalf("RunOnce = %v, want %v", err, failure)
+			}
+			for i := 0; i < 2; i++ {
+				select {
+				case r := <-results:
+					if !errors.Is(r.Err, failure) {
+						t.Errorf("Settle = %+v, want the settlement failure", r)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("Settle did not return")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package convoy
+
+import (
+	"time"
+)
+
+// reconnectBackoff is the wait before accepting the next session after a connection failure
+const reconnectBackoff = 5 * time.Second
+
+// ErrorClass is the kind of failure an error represents, which decides how the convoy reacts to it
+type ErrorClass int
+
+const (
+	// ErrorUnknown is any other error. It stops the convoy when accepting a session and is retried when settling.
+	ErrorUnknown ErrorClass = iota
+	// ErrorTimeout means no session became available in time. The convoy tries again.
+	ErrorTimeout
+	// ErrorThrottle means the broker is busy. The convoy backs off before trying again, see WithOnThrottle.
+	ErrorThrottle
+	// ErrorLockLost means the lock on a message or its session is gone. The session is released for redelivery.
+	ErrorLockLost
+	// ErrorConnection means the connection to the namespace failed. The convoy reconnects after a short backoff.
+	ErrorConnection
+	// ErrorFatal stops the convoy.
+	ErrorFatal
+)
+
+// WithErrorClassifier classifies errors with fn before the built-in classification, which relies on the AMQP error
+// conditions reported by Service Bus, e.g. to adapt to an SDK version that reports them differently or to treat
+// further errors as transient. Errors fn returns ErrorUnknown for are classified by the built-in classifier.
+func WithErrorClassifier(fn func(error) ErrorClass) Option {
+	return func(c *Convoy) {
+		c.classifier = fn
+	}
+}
+
+// classify returns the class of err, asking the user's classifier first
+func (c *Convoy) classify(err error) ErrorClass {
+	if c.classifier != nil {
+		if class := c.classifier(err); class != ErrorUnknown {
+			return class
+		}
+	}
+
+	switch {
+	case isTimeout(err):
+		return ErrorTimeout
+	case isServerBusy(err):
+		return ErrorThrottle
+	case isEntityUnavailable(err), isHandlerPanic(err):
+		return ErrorFatal
+	case isConnectionError(err):
+		return ErrorConnection
+	case isLockLost(err):
+		return ErrorLockLost
+	default:
+		return ErrorUnknown
+	}
+}
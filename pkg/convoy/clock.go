@@ -0,0 +1,28 @@
+package convoy
+
+import "time"
+
+// clockBase anchors the monotonic clock of the convoy
+var clockBase = time.Now()
+
+// monotonicNow returns the time elapsed since clockBase. It is measured with the monotonic clock, so unlike wall-clock
+// timestamps it cannot jump when NTP or the hypervisor adjusts the system time, which makes it the basis of all expiry
+// decisions of the watchdog.
+func monotonicNow() time.Duration {
+	return time.Since(clockBase)
+}
+
+// monotonicNow returns the reading of the monotonic clock of the convoy, the one set with withClock or else
+// monotonicNow
+func (c *Convoy) monotonicNow() time.Duration {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return monotonicNow()
+}
+
+// monotonicAt converts t to a reading of the monotonic clock of the convoy. Timestamps taken with time.Now carry a
+// monotonic reading and convert exactly; others go through the wall clock.
+func (c *Convoy) monotonicAt(t time.Time) time.Duration {
+	return c.monotonicNow() - time.Since(t)
+}
@@ -0,0 +1,142 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithCommutativeProperty relaxes the convoy guarantee for messages whose application property name is true: such a
+// commutative message declares that its effect does not depend on its order relative to its commutative neighbours,
+// so up to maxParallel consecutive commutative messages of a session are handled concurrently. Every other message is
+// a barrier: it is handled only after all messages before it are settled, and alone, so the ordering between barriers
+// and everything else stays strict. A run of commutative messages between two barriers may thus be handled and
+// completed in any order, but never before the barrier preceding it nor after the barrier following it.
+//
+// Handlers running concurrently share the session, so a commutative handler that returns ErrRetryLater releases the
+// session for the handlers beside it too, whose messages are then redelivered, and a lost session lock cancels all of
+// them. The watchdog considers the session busy while any of them runs and finds it hung by the oldest one. The mode
+// cannot be combined with WithAsyncSettlement, WithPipelinedDecode or WithGlobalOrdering, which rely on messages being
+// settled one at a time.
+func WithCommutativeProperty(name string, maxParallel int) Option {
+	return func(c *Convoy) {
+		c.commutativeProperty = name
+		c.commutativeParallel = maxParallel
+	}
+}
+
+// validateCommutative checks the commutative mode against the options it cannot be combined with
+func (c *Convoy) validateCommutative() error {
+	if c.commutativeProperty == "" {
+		return nil
+	}
+	if c.commutativeParallel < 1 {
+		return errors.New("commutative parallelism must be positive")
+	}
+	if c.asyncDepth > 0 || c.decode != nil || c.globalOrder != nil {
+		return errors.New("commutative messages cannot be combined with asynchronous settlement, pipelined decoding or global ordering")
+	}
+	return nil
+}
+
+// isCommutative reports whether msg is flagged as commutative
+func (c *Convoy) isCommutative(msg *servicebus.Message) bool {
+	if c.commutativeProperty == "" {
+		return false
+	}
+	v, ok := msg.UserProperties[c.commutativeProperty]
+	if !ok || v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	b, err := strconv.ParseBool(fmt.Sprint(v))
+	return err == nil && b
+}
+
+// commutativeGroup tracks the commutative messages of a session that are being handled concurrently
+type commutativeGroup struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+func newCommutativeGroup(maxParallel int) *commutativeGroup {
+	return &commutativeGroup{slots: make(chan struct{}, maxParallel)}
+}
+
+// fail records the first error of a commutative message
+func (g *commutativeGroup) fail(err error) {
+	g.mu.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mu.Unlock()
+}
+
+// failed returns the first error of a commutative message
+func (g *commutativeGroup) failed() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+// wait waits for all commutative messages in flight and returns the first error of any of them
+func (g *commutativeGroup) wait() error {
+	g.wg.Wait()
+	return g.failed()
+}
+
+// handleCommutative handles a commutative message in the background once a slot is free, and a barrier message after
+// all messages before it are settled
+func (sh *StepSessionHandler) handleCommutative(ctx context.Context, msg *servicebus.Message) error {
+	sh.RLock()
+	g := sh.commutative
+	sh.RUnlock()
+
+	if !sh.convoy.isCommutative(msg) {
+		if err := g.wait(); err != nil {
+			return err
+		}
+		return sh.handleSafely(ctx, msg)
+	}
+
+	select {
+	case g.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if err := g.failed(); err != nil {
+		<-g.slots
+		return err
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() { <-g.slots }()
+		if err := sh.handleSafely(ctx, msg); err != nil {
+			g.fail(err)
+		}
+	}()
+	return nil
+}
+
+// awaitCommutative waits for the commutative messages of the session still in flight
+func (sh *StepSessionHandler) awaitCommutative() error {
+	sh.RLock()
+	g := sh.commutative
+	sh.RUnlock()
+
+	if g == nil {
+		return nil
+	}
+	return g.wait()
+}
@@ -0,0 +1,131 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestCommutativeMessagesStayBetweenBarriers(t *testing.T) {
+	broker := newFakeBroker()
+	msgs := broker.add("a", "c1", "c2", "barrier", "c4")
+	for _, msg := range []*servicebus.Message{msgs[0], msgs[1], msgs[3]} {
+		msg.UserProperties = map[string]interface{}{"commutative": true}
+	}
+
+	var running sync.WaitGroup
+	running.Add(2)
+	completed := func() map[string]bool {
+		done := map[string]bool{}
+		for _, s := range broker.settlements() {
+			done[s.messageID] = s.outcome == OutcomeCompleted
+		}
+		return done
+	}
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		switch string(msg.Data) {
+		case "c1", "c2":
+			// Both messages of the run must be handled at the same time
+			running.Done()
+			wait := make(chan struct{})
+			go func() {
+				running.Wait()
+				close(wait)
+			}()
+			select {
+			case <-wait:
+			case <-time.After(5 * time.Second):
+				return errors.New("commutative messages were not handled concurrently")
+			}
+		case "barrier":
+			if done := completed(); !done["a-1"] || !done["a-2"] {
+				return errors.New("barrier handled before the messages preceding it were completed")
+			}
+		case "c4":
+			if !completed()["a-3"] {
+				return errors.New("commutative message handled before the barrier preceding it was completed")
+			}
+		}
+		return nil
+	}, WithCommutativeProperty("commutative", 2))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if n := broker.remaining(); n != 0 {
+		t.Errorf("%d messages left on the broker", n)
+	}
+}
+
+// startInvocations runs a handler invocation of sh for each of ids, each blocked until its channel is closed or it is
+// cancelled, and returns once all of them are running. The result of each invocation is sent on its channel in errs.
+func startInvocations(t *testing.T, ids ...string) (sh *StepSessionHandler, release, errs map[string]chan error) {
+	t.Helper()
+	release, errs = map[string]chan error{}, map[string]chan error{}
+	for _, id := range ids {
+		release[id], errs[id] = make(chan error), make(chan error, 1)
+	}
+
+	started := make(chan struct{})
+	c := newTestConvoy(t, newFakeBroker(), func(ctx context.Context, msg *servicebus.Message) error {
+		started <- struct{}{}
+		select {
+		case err := <-release[msg.ID]:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	sh = &StepSessionHandler{convoy: c}
+	for _, id := range ids {
+		msg := servicebus.NewMessageFromString(id)
+		msg.ID = id
+		go func() {
+			errs[msg.ID] <- sh.runHandler(context.Background(), msg)
+		}()
+		<-started
+	}
+	return sh, release, errs
+}
+
+func TestProcessingSinceTracksEveryInvocation(t *testing.T) {
+	sh, release, errs := startInvocations(t, "first", "second")
+
+	if _, processing := sh.processingSince(); !processing {
+		t.Fatal("processingSince reports no handler while two are running")
+	}
+
+	release["first"] <- nil
+	if err := <-errs["first"]; err != nil {
+		t.Fatalf("first invocation returned %v", err)
+	}
+	if _, processing := sh.processingSince(); !processing {
+		t.Error("processingSince reports no handler after the first of two returned")
+	}
+
+	release["second"] <- nil
+	<-errs["second"]
+	if _, processing := sh.processingSince(); processing {
+		t.Error("processingSince reports a handler after all returned")
+	}
+}
+
+func TestCancelRunningCancelsEveryInvocation(t *testing.T) {
+	sh, _, errs := startInvocations(t, "first", "second")
+
+	if !sh.cancelRunning(errLockLost) {
+		t.Fatal("cancelRunning reports no handler cancelled")
+	}
+	for id, errc := range errs {
+		if err := <-errc; !errors.Is(err, errLockLost) {
+			t.Errorf("invocation %s returned %v, want %v", id, err, errLockLost)
+		}
+	}
+	if sh.cancelRunning(errLockLost) {
+		t.Error("cancelRunning cancelled a handler after all returned")
+	}
+}
@@ -0,0 +1,144 @@
+package convoy
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings of a convoy read from environment variables
+type Config struct {
+	ConnectionString      string
+	QueueName             string
+	TopicName             string
+	SubscriptionName      string
+	IdleTimeout           time.Duration
+	HandlerTimeout        time.Duration
+	WatchdogInterval      time.Duration
+	HardShutdownTimeout   time.Duration
+	ShardIndex            int
+	ShardTotal            int
+	MaxConcurrentSessions int
+	AuditLogFile          string
+	HealthAddr            string
+	WorkerID              string
+	UseWebSocket          bool
+}
+
+// LoadConfig reads the convoy settings from environment variables whose names start with prefix, so that several
+// convoys in one process can be configured independently, e.g. CONVOY_A_CONNECTION_STRING and CONVOY_B_QUEUE_NAME.
+// With an empty prefix the connection string is read from SERVICEBUS_CONNECTION_STRING and the remaining settings
+// from their unprefixed names. Unset optional settings keep their defaults.
+func LoadConfig(prefix string) (Config, error) {
+	name := func(key string) string {
+		if prefix == "" && key == "CONNECTION_STRING" {
+			return "SERVICEBUS_CONNECTION_STRING"
+		}
+		return prefix + key
+	}
+	env := func(key string) string {
+		return os.Getenv(name(key))
+	}
+
+	cfg := Config{
+		ConnectionString: env("CONNECTION_STRING"),
+		QueueName:        env("QUEUE_NAME"),
+		TopicName:        env("TOPIC_NAME"),
+		SubscriptionName: env("SUBSCRIPTION_NAME"),
+		AuditLogFile:     env("AUDIT_LOG_FILE"),
+		HealthAddr:       env("HEALTH_ADDR"),
+		WorkerID:         env("WORKER_ID"),
+		IdleTimeout:      defaultIdleTimeout,
+		WatchdogInterval: defaultWatchdogInterval,
+	}
+	if v := env("USE_WEBSOCKET"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %w", name("USE_WEBSOCKET"), err)
+		}
+		cfg.UseWebSocket = parsed
+	}
+	if cfg.ConnectionString == "" {
+		return cfg, fmt.Errorf("expected environment variable %s not set", name("CONNECTION_STRING"))
+	}
+	subscription := cfg.TopicName != "" || cfg.SubscriptionName != ""
+	switch {
+	case cfg.QueueName != "" && subscription:
+		return cfg, fmt.Errorf("set either %s or %s and %s, not both", name("QUEUE_NAME"), name("TOPIC_NAME"), name("SUBSCRIPTION_NAME"))
+	case subscription && (cfg.TopicName == "" || cfg.SubscriptionName == ""):
+		return cfg, fmt.Errorf("expected both environment variables %s and %s to be set", name("TOPIC_NAME"), name("SUBSCRIPTION_NAME"))
+	case !subscription && cfg.QueueName == "":
+		return cfg, fmt.Errorf("expected environment variable %s, or %s and %s, not set", name("QUEUE_NAME"), name("TOPIC_NAME"), name("SUBSCRIPTION_NAME"))
+	}
+
+	durations := []struct {
+		key string
+		dst *time.Duration
+	}{
+		{"IDLE_TIMEOUT", &cfg.IdleTimeout},
+		{"HANDLER_TIMEOUT", &cfg.HandlerTimeout},
+		{"WATCHDOG_INTERVAL", &cfg.WatchdogInterval},
+		{"HARD_SHUTDOWN_TIMEOUT", &cfg.HardShutdownTimeout},
+	}
+	for _, d := range durations {
+		if v := env(d.key); v != "" {
+			parsed, err := time.ParseDuration(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s: %w", name(d.key), err)
+			}
+			*d.dst = parsed
+		}
+	}
+
+	ints := []struct {
+		key string
+		dst *int
+	}{
+		{"SHARD_INDEX", &cfg.ShardIndex},
+		{"SHARD_TOTAL", &cfg.ShardTotal},
+		{"MAX_CONCURRENT_SESSIONS", &cfg.MaxConcurrentSessions},
+	}
+	for _, i := range ints {
+		if v := env(i.key); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s: %w", name(i.key), err)
+			}
+			if parsed < 0 {
+				return cfg, fmt.Errorf("invalid %s: %d is negative", name(i.key), parsed)
+			}
+			*i.dst = parsed
+		}
+	}
+
+	return cfg, nil
+}
+
+// Options converts the settings to convoy options. The audit log file and the health endpoint are left to the caller,
+// who owns the file and the listener.
+func (cfg Config) Options() []Option {
+	opts := []Option{
+		WithIdleTimeout(cfg.IdleTimeout),
+		WithWatchdogInterval(cfg.WatchdogInterval),
+		WithHandlerTimeout(cfg.HandlerTimeout),
+		WithHardShutdownTimeout(cfg.HardShutdownTimeout),
+	}
+	if cfg.TopicName != "" {
+		opts = append(opts, WithTopicSubscription(cfg.TopicName, cfg.SubscriptionName))
+	}
+	if cfg.UseWebSocket {
+		opts = append(opts, WithWebSocket())
+	}
+	if cfg.WorkerID != "" {
+		opts = append(opts, WithWorkerID(cfg.WorkerID))
+	}
+	if cfg.ShardTotal > 0 {
+		opts = append(opts, WithShard(cfg.ShardIndex, cfg.ShardTotal))
+	}
+	if cfg.MaxConcurrentSessions > 0 {
+		opts = append(opts, WithConcurrentSessions(cfg.MaxConcurrentSessions))
+	}
+
+	return opts
+}
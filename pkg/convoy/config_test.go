@@ -0,0 +1,128 @@
+package convoy
+
+import (
+	"strings"
+	"testing"
+)
+
+// setConfigEnv sets the required settings of a queue convoy under prefix, followed by the key=value pairs of extra
+func setConfigEnv(t *testing.T, prefix string, extra ...string) {
+	t.Helper()
+	t.Setenv(prefix+"CONNECTION_STRING", "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=secret")
+	for _, kv := range extra {
+		parts := strings.SplitN(kv, "=", 2)
+		t.Setenv(prefix+parts[0], parts[1])
+	}
+}
+
+func TestLoadConfigMaxConcurrentSessions(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr string
+	}{
+		{value: "", want: 0},
+		{value: "8", want: 8},
+		{value: "0", want: 0},
+		{value: "eight", wantErr: "invalid TEST_MAX_CONCURRENT_SESSIONS"},
+		{value: "2.5", wantErr: "invalid TEST_MAX_CONCURRENT_SESSIONS"},
+		{value: "-1", wantErr: "invalid TEST_MAX_CONCURRENT_SESSIONS: -1 is negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			setConfigEnv(t, "TEST_", "QUEUE_NAME=orders", "MAX_CONCURRENT_SESSIONS="+tt.value)
+			cfg, err := LoadConfig("TEST_")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadConfig = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.MaxConcurrentSessions != tt.want {
+				t.Errorf("MaxConcurrentSessions = %d, want %d", cfg.MaxConcurrentSessions, tt.want)
+			}
+			c := &Convoy{}
+			for _, opt := range cfg.Options() {
+				opt(c)
+			}
+			if want := tt.want; want > 0 && c.concurrentSessions != want {
+				t.Errorf("options configure %d concurrent sessions, want %d", c.concurrentSessions, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEntitySelection(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     []string
+		queue   string
+		topic   string
+		wantErr string
+	}{
+		{name: "queue", env: []string{"QUEUE_NAME=orders"}, queue: "orders"},
+		{name: "topic subscription", env: []string{"TOPIC_NAME=events", "SUBSCRIPTION_NAME=billing"}, topic: "events"},
+		{
+			name:    "queue and topic",
+			env:     []string{"QUEUE_NAME=orders", "TOPIC_NAME=events", "SUBSCRIPTION_NAME=billing"},
+			wantErr: "set either TEST_QUEUE_NAME or TEST_TOPIC_NAME and TEST_SUBSCRIPTION_NAME, not both",
+		},
+		{
+			name:    "queue and subscription",
+			env:     []string{"QUEUE_NAME=orders", "SUBSCRIPTION_NAME=billing"},
+			wantErr: "not both",
+		},
+		{
+			name:    "topic without subscription",
+			env:     []string{"TOPIC_NAME=events"},
+			wantErr: "expected both environment variables TEST_TOPIC_NAME and TEST_SUBSCRIPTION_NAME to be set",
+		},
+		{
+			name:    "subscription without topic",
+			env:     []string{"SUBSCRIPTION_NAME=billing"},
+			wantErr: "expected both environment variables TEST_TOPIC_NAME and TEST_SUBSCRIPTION_NAME to be set",
+		},
+		{
+			name:    "no entity",
+			wantErr: "expected environment variable TEST_QUEUE_NAME, or TEST_TOPIC_NAME and TEST_SUBSCRIPTION_NAME, not set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfigEnv(t, "TEST_", tt.env...)
+			cfg, err := LoadConfig("TEST_")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("LoadConfig = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			if cfg.QueueName != tt.queue || cfg.TopicName != tt.topic {
+				t.Errorf("queue %q and topic %q, want %q and %q", cfg.QueueName, cfg.TopicName, tt.queue, tt.topic)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithoutPrefix(t *testing.T) {
+	t.Setenv("SERVICEBUS_CONNECTION_STRING", "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=secret")
+	t.Setenv("QUEUE_NAME", "orders")
+	t.Setenv("TOPIC_NAME", "")
+	t.Setenv("SUBSCRIPTION_NAME", "")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.QueueName != "orders" || cfg.ConnectionString == "" {
+		t.Errorf("config %+v, want queue orders read from unprefixed variables", cfg)
+	}
+}
@@ -0,0 +1,57 @@
+package convoy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidConnectionString is returned by New when the connection string is malformed. The error names the missing
+// or malformed part; use errors.Is to detect it.
+var ErrInvalidConnectionString = errors.New("invalid connection string")
+
+// validateConnectionString checks that connStr carries an endpoint and credentials before the SDK parses it, so that
+// a malformed connection string fails with an actionable error. Only the connection string is checked; whether the
+// namespace exists and accepts the credentials is up to the SDK.
+func validateConnectionString(connStr string) error {
+	invalid := func(format string, v ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidConnectionString, fmt.Sprintf(format, v...))
+	}
+
+	if strings.TrimSpace(connStr) == "" {
+		return invalid("connection string is empty")
+	}
+
+	fields := make(map[string]string)
+	for _, part := range strings.Split(connStr, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return invalid("%q is not a key=value pair", part)
+		}
+		fields[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+
+	endpoint, ok := fields["endpoint"]
+	if !ok || endpoint == "" {
+		return invalid("missing Endpoint")
+	}
+	if u, err := url.Parse(endpoint); err != nil || u.Scheme != "sb" || u.Host == "" {
+		return invalid("Endpoint %q is not of the form sb://<namespace>.servicebus.windows.net/", endpoint)
+	}
+
+	if fields["sharedaccesssignature"] != "" {
+		return nil
+	}
+	if fields["sharedaccesskeyname"] == "" {
+		return invalid("missing SharedAccessKeyName")
+	}
+	if fields["sharedaccesskey"] == "" {
+		return invalid("missing SharedAccessKey")
+	}
+
+	return nil
+}
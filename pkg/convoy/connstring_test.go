@@ -0,0 +1,73 @@
+package convoy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateConnectionString(t *testing.T) {
+	tests := []struct {
+		name    string
+		connStr string
+		wantErr string
+	}{
+		{
+			name:    "shared access key",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=c2VjcmV0PT0=",
+		},
+		{
+			name:    "shared access signature",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessSignature=SharedAccessSignature sr=x&sig=y",
+		},
+		{
+			name:    "keys in any case with spaces and a trailing separator",
+			connStr: " endpoint = sb://example.servicebus.windows.net/ ; sharedaccesskeyname=listen;SHAREDACCESSKEY=secret;",
+		},
+		{name: "empty", connStr: "", wantErr: "empty"},
+		{name: "blank", connStr: "  ", wantErr: "empty"},
+		{name: "not key=value", connStr: "Endpoint=sb://example.servicebus.windows.net/;listen", wantErr: `"listen" is not a key=value pair`},
+		{name: "missing endpoint", connStr: "SharedAccessKeyName=listen;SharedAccessKey=secret", wantErr: "missing Endpoint"},
+		{name: "empty endpoint", connStr: "Endpoint=;SharedAccessKeyName=listen;SharedAccessKey=secret", wantErr: "missing Endpoint"},
+		{
+			name:    "endpoint not sb",
+			connStr: "Endpoint=https://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=secret",
+			wantErr: "is not of the form sb://",
+		},
+		{
+			name:    "endpoint without host",
+			connStr: "Endpoint=sb:///;SharedAccessKeyName=listen;SharedAccessKey=secret",
+			wantErr: "is not of the form sb://",
+		},
+		{
+			name:    "missing key name",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKey=secret",
+			wantErr: "missing SharedAccessKeyName",
+		},
+		{
+			name:    "missing key",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen",
+			wantErr: "missing SharedAccessKey",
+		},
+		{
+			name:    "empty key",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=",
+			wantErr: "missing SharedAccessKey",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConnectionString(tt.connStr)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateConnectionString = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConnectionString) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateConnectionString = %v, want %v naming %q", err, ErrInvalidConnectionString, tt.wantErr)
+			}
+		})
+	}
+}
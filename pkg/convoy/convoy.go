@@ -0,0 +1,881 @@
+// Package convoy implements the sequential convoy pattern on Azure Service Bus: it accepts the sessions of a queue
+// and hands the messages of each session to a handler strictly in order.
+package convoy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+const (
+	defaultIdleTimeout      = 30 * time.Second
+	defaultWatchdogInterval = 10 * time.Second
+	defaultSettleAttempts   = 3
+	defaultEmptyAccepts     = 3
+	defaultSettleBackoff    = 500 * time.Millisecond
+)
+
+// ErrHardShutdown is returned by Run when a handler did not finish within the hard shutdown timeout
+var ErrHardShutdown = errors.New("handler did not finish before hard shutdown timeout")
+
+// Convoy receives sessions from a queue or a topic subscription, one at a time unless WithConcurrentSessions is set,
+// and hands the messages of each session to a handler in order
+type Convoy struct {
+	namespace *servicebus.Namespace
+	source    entity
+	queueName string
+
+	// Topic subscription received from instead of a queue, see WithTopicSubscription
+	topicName        string
+	subscriptionName string
+
+	handler HandlerFunc
+	audit   AuditSink
+	metrics Metrics
+	logger  Logger
+
+	idleTimeout         time.Duration
+	handlerTimeout      time.Duration
+	lockDuration        time.Duration
+	watchdogInterval    time.Duration
+	hardShutdownTimeout time.Duration
+	settleAttempts      int
+	settleBackoff       time.Duration
+	emptyAccepts        int
+	shardIndex          int
+	shardTotal          int
+	idempotencyProperty string
+	idempotencyStore    IdempotencyStore
+	inFlight            *byteBudget
+	breaker             *sessionBreaker
+	expiryAlert         *expiryRate
+	correlationProperty string
+	decode              DecodeFunc
+	strategy            ReceiveStrategy
+	forward             *deadLetterForward
+	retry               *retryQueue
+	onThrottle          func(retryAfter time.Duration)
+	concurrentSessions  int
+	autoScale           *autoScaler
+	poolSize            int
+	pool                *handlerPool
+	skipOnDeadLetter    bool
+	expiryGrace         time.Duration
+	expiryConfirmations int
+	expvarPrefix        string
+	expvar              *expvarMetrics
+	isolated            *isolatedMetrics
+	stickySession       string
+	classifier          func(error) ErrorClass
+	emptySessionTimeout time.Duration
+	sendAttempts        int
+	sendBackoff         time.Duration
+	invariantChecks     bool
+	preProcess          PreProcessFunc
+	asyncDepth          int
+	onAcceptError       func(err error) AcceptDecision
+	activeSessions      int64
+	lastActivity        int64
+	workerID            string
+	workerProperty      string
+	lockValidation      bool
+	replies             replySenders
+	maxExpiries         int
+	expiries            int64
+	attempts            attemptCounter
+	onReport            func(RunReport)
+	onFirstMessage      func(ctx context.Context, msg *servicebus.Message) error
+	memoryGuard         *memoryGuard
+	beginTx             func(ctx context.Context) (Tx, error)
+	latencyLog          time.Duration
+	latencies           chan time.Duration
+	minSequence         func(sessionID string) int64
+	skipExpired         bool
+	onDrained           func()
+	events              *eventQueue
+	globalOrder         *completionOrder
+	maxPerVisit         int
+	streamHandler       StreamHandlerFunc
+	processingLog       bool
+	unroutableReply     UnroutableReplyPolicy
+	maxHoldTime         time.Duration
+	failFast            bool
+	commutativeProperty string
+	commutativeParallel int
+	emptyStreak         int64
+	drained             int32
+	heartbeatLog        time.Duration
+	maxDeliveryCount    uint32
+	namespaceOptions    []servicebus.NamespaceOption
+	webSocket           bool
+	tlsConfig           *tls.Config
+
+	synchronous    bool
+	clock          func() time.Duration
+	maxRunDuration time.Duration
+	stopping       int32
+
+	deferred   deferredSet
+	dedupCheck sync.Once
+	settingsMu sync.RWMutex
+	running    int32
+
+	// State served by DebugHandler
+	redactedConnStr string
+	recent          eventRing
+	sessions        sync.Map
+	debugMu         sync.Mutex
+	runStats        *runStats
+	lastErr         error
+	lastErrAt       time.Time
+}
+
+// Option configures a Convoy
+type Option func(*Convoy)
+
+// WithAuditSink records the outcome of every settled message in sink. The sink is closed when the convoy is closed.
+func WithAuditSink(sink AuditSink) Option {
+	return func(c *Convoy) {
+		c.audit = sink
+	}
+}
+
+// WithMetrics reports the convoy's metrics to m. Updates reach m asynchronously; if m falls behind or panics, updates
+// are dropped rather than holding up the processing of messages.
+func WithMetrics(m Metrics) Option {
+	return func(c *Convoy) {
+		c.metrics = m
+	}
+}
+
+// WithIdleTimeout sets how long a session may go without processing a message before the watchdog closes it. While a
+// handler is running, the same timeout applies to its heartbeats: a handler silent for longer is considered hung, is
+// cancelled and its message abandoned.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.idleTimeout = d
+	}
+}
+
+// WithHandlerTimeout bounds the time the handler may spend on a single message through the deadline of its context.
+// A handler that returns context.DeadlineExceeded has its message abandoned and redelivered.
+func WithHandlerTimeout(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.handlerTimeout = d
+	}
+}
+
+// WithWatchdogInterval sets how often the watchdog checks a session that has not started yet, and how often it checks
+// again once a session went stale, e.g. to confirm it with WithExpiryGrace or to detect a hung handler. An active
+// session is checked right when its idle timeout would pass, regardless of the interval.
+func WithWatchdogInterval(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.watchdogInterval = d
+	}
+}
+
+// WithHardShutdownTimeout bounds how long Run drains the in-flight handler once its context is cancelled. When the
+// timeout elapses the context of the handler is cancelled, the session is closed and Run returns ErrHardShutdown,
+// leaving the message unsettled for redelivery. A handler that finishes within the timeout has its message settled as
+// usual. Zero waits for the handler indefinitely.
+func WithHardShutdownTimeout(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.hardShutdownTimeout = d
+	}
+}
+
+// WithSettleRetry retries failed settlements of a message up to attempts times in total, doubling backoff after each
+// failure. A lost lock is never retried: the message is left to be redelivered by the broker. Settlement failures that
+// persist after the last attempt stop the convoy.
+func WithSettleRetry(attempts int, backoff time.Duration) Option {
+	return func(c *Convoy) {
+		c.settleAttempts = attempts
+		c.settleBackoff = backoff
+	}
+}
+
+// WithEmptyAccepts sets how many consecutive accept attempts must time out before RunOnce considers the queue drained
+func WithEmptyAccepts(n int) Option {
+	return func(c *Convoy) {
+		c.emptyAccepts = n
+	}
+}
+
+// WithShard restricts the convoy to the sessions whose ID hashes into shard index of total. Run total instances with
+// indexes 0 to total-1 to process every session exactly once across them.
+func WithShard(index, total int) Option {
+	return func(c *Convoy) {
+		c.shardIndex = index
+		c.shardTotal = total
+	}
+}
+
+// New creates a convoy that processes the sessions of queue qName, or of the subscription set with
+// WithTopicSubscription if qName is empty, with handler. It validates the options and the connection string but does
+// not connect: the connection is established by Run, which retries while the broker is unreachable, so a service can
+// start during a broker outage. Invalid settings therefore fail New while connectivity problems are retried, and Close
+// is safe on a convoy that never connected.
+func New(connStr, qName string, handler HandlerFunc, opts ...Option) (*Convoy, error) {
+	c, err := newConvoy(handler, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateConnectionString(connStr); err != nil {
+		return nil, err
+	}
+	c.redactedConnStr = redactConnectionString(connStr)
+	if err = checkNamespaceOptions(connStr, c.namespaceOptions); err != nil {
+		return nil, err
+	}
+
+	// Create a client to communicate with a Service Bus Namespace.
+	nsOpts := append([]servicebus.NamespaceOption{servicebus.NamespaceWithConnectionString(connStr)}, c.namespaceOptions...)
+	ns, err := servicebus.NewNamespace(nsOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, c.open(ns, qName)
+}
+
+// NewWithNamespace creates a convoy on a namespace shared with other convoys or clients. The namespace carries the
+// credentials and endpoint; each convoy still opens its own AMQP link to its queue. The caller keeps ownership of ns:
+// Close only releases the convoy's queue client and leaves the namespace usable by others. Options that configure the
+// namespace, such as WithWebSocket, are rejected since the namespace is already built.
+func NewWithNamespace(ns *servicebus.Namespace, qName string, handler HandlerFunc, opts ...Option) (*Convoy, error) {
+	c, err := newConvoy(handler, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.namespaceOptions) > 0 {
+		return nil, errors.New("namespace options cannot be applied to a shared namespace")
+	}
+
+	return c, c.open(ns, qName)
+}
+
+// newConvoy applies opts over the defaults and validates the result
+func newConvoy(handler HandlerFunc, opts []Option) (*Convoy, error) {
+	c := &Convoy{
+		handler: handler,
+		metrics: nopMetrics{},
+		logger:  defaultLogger(),
+
+		idleTimeout:         defaultIdleTimeout,
+		watchdogInterval:    defaultWatchdogInterval,
+		settleAttempts:      defaultSettleAttempts,
+		settleBackoff:       defaultSettleBackoff,
+		emptyAccepts:        defaultEmptyAccepts,
+		strategy:            ReceiveOneByOne,
+		emptySessionTimeout: defaultEmptySessionTimeout,
+		sendAttempts:        defaultSendAttempts,
+		sendBackoff:         defaultSendBackoff,
+		workerID:            defaultWorkerID(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	if c.latencyLog > 0 {
+		c.latencies = make(chan time.Duration, latencyReservoirSize)
+	}
+	c.labelWorker()
+	if _, nop := c.metrics.(nopMetrics); !nop {
+		c.isolated = isolateMetrics(c.metrics, c.logf)
+		c.metrics = c.isolated
+	}
+	if c.expvarPrefix != "" {
+		c.expvar = newExpvarMetrics(c.expvarPrefix, c.metrics)
+		c.expvar.publishWorker(c.workerID)
+		c.metrics = c.expvar
+	}
+
+	return c, nil
+}
+
+// open creates the client of the source entity of the convoy on ns
+func (c *Convoy) open(ns *servicebus.Namespace, qName string) error {
+	// Create queue or subscription receiver
+	source, err := c.openEntity(ns, qName)
+	if err != nil {
+		return err
+	}
+
+	if c.forward != nil {
+		if c.forward.sender, err = ns.NewQueue(c.forward.target); err != nil {
+			return err
+		}
+	}
+	if c.retry != nil {
+		if c.retry.sender, err = ns.NewQueue(c.retry.name); err != nil {
+			return err
+		}
+	}
+
+	c.replies.open = func(entity string) (replySender, error) {
+		q, err := ns.NewQueue(entity)
+		if err != nil {
+			return nil, err
+		}
+		return q, nil
+	}
+	c.namespace = ns
+	c.source = source
+	return nil
+}
+
+// validate checks the combination of options applied to the convoy
+func (c *Convoy) validate() error {
+	if err := c.validateStreamHandler(); err != nil {
+		return err
+	}
+	if c.handler == nil {
+		return errors.New("a handler or a stream handler is required")
+	}
+	if err := c.validateCommutative(); err != nil {
+		return err
+	}
+	if c.breaker != nil && (c.breaker.threshold < 1 || c.breaker.cooldown <= 0) {
+		return errors.New("circuit breaker threshold and cooldown must be positive")
+	}
+	if c.expiryAlert != nil && (c.expiryAlert.max < 0 || c.expiryAlert.alert == nil) {
+		return errors.New("expiry alert requires a non-negative threshold and a callback")
+	}
+	if c.webSocket && c.tlsConfig != nil {
+		return errors.New("a TLS config cannot be combined with WebSockets")
+	}
+	if c.forward != nil {
+		if err := c.forward.validate(); err != nil {
+			return err
+		}
+	}
+	if c.retry != nil {
+		if err := c.retry.validate(); err != nil {
+			return err
+		}
+	}
+	if c.memoryGuard != nil {
+		if err := c.memoryGuard.validate(); err != nil {
+			return err
+		}
+	}
+	if c.heartbeatLog < 0 || c.latencyLog < 0 {
+		return errors.New("heartbeat and latency log intervals must not be negative")
+	}
+	if c.asyncDepth < 0 {
+		return errors.New("async settlement depth must not be negative")
+	}
+	if c.concurrentSessions < 0 || c.poolSize < 0 {
+		return errors.New("concurrent sessions and handler pool size must not be negative")
+	}
+	if c.autoScale != nil && (c.autoScale.min < 1 || c.autoScale.max < c.autoScale.min || c.concurrentSessions > 0) {
+		return errors.New("auto scaling needs 1 <= min <= max and cannot be combined with a fixed number of concurrent sessions")
+	}
+	if c.stickySession != "" && (c.concurrentSessions > 1 || c.autoScale != nil || c.shardTotal > 0) {
+		return errors.New("a sticky session cannot be combined with concurrent sessions, auto scaling or sharding")
+	}
+	if c.expiryGrace < 0 || c.expiryConfirmations < 0 {
+		return errors.New("expiry grace and confirmations must not be negative")
+	}
+	if c.unroutableReply < UnroutableReplyDeadLetter || c.unroutableReply > UnroutableReplyFail {
+		return errors.New("unknown unroutable reply policy")
+	}
+	if c.maxHoldTime < 0 {
+		return errors.New("max session hold time must not be negative")
+	}
+	if c.maxPerVisit < 0 {
+		return errors.New("max messages per session visit must not be negative")
+	}
+	if c.maxExpiries < 0 {
+		return errors.New("max consecutive expiries must not be negative")
+	}
+	if c.maxRunDuration < 0 {
+		return errors.New("max run duration must not be negative")
+	}
+	if c.strategy.window < 1 {
+		return errors.New("receive window must be at least 1")
+	}
+	if c.lockDuration < 0 {
+		return errors.New("lock duration must not be negative")
+	}
+	if c.emptyAccepts < 1 {
+		return errors.New("empty accepts must be at least 1")
+	}
+	if c.settleAttempts < 1 || c.sendAttempts < 1 {
+		return errors.New("settle and send attempts must be at least 1")
+	}
+	if c.inFlight != nil && c.inFlight.max <= 0 {
+		return errors.New("max in-flight bytes must be positive")
+	}
+	if c.idleTimeout <= 0 || c.watchdogInterval <= 0 {
+		return errors.New("idle timeout and watchdog interval must be positive")
+	}
+	if c.shardTotal < 0 || (c.shardTotal > 0 && (c.shardIndex < 0 || c.shardIndex >= c.shardTotal)) {
+		return fmt.Errorf("invalid shard %d of %d", c.shardIndex, c.shardTotal)
+	}
+
+	return nil
+}
+
+// Run accepts and processes sessions until ctx is cancelled or an unrecoverable error occurs. Cancelling ctx shuts the
+// convoy down gracefully: it stops accepting sessions, drains the current session and closes its receiver, and Run
+// returns nil, or ErrHardShutdown if the hard shutdown timeout cut the drain short. Any other error is returned as is.
+func (c *Convoy) Run(ctx context.Context) error {
+	_, err := c.run(ctx, false)
+	return err
+}
+
+// RunOnce accepts and processes sessions until the queue is drained, i.e. no session became available within the
+// accept timeout for the configured number of consecutive attempts, and returns what was processed. Unlike Run it
+// suits one-shot jobs that exit once all available work is done.
+func (c *Convoy) RunOnce(ctx context.Context) (Summary, error) {
+	return c.run(ctx, true)
+}
+
+func (c *Convoy) run(ctx context.Context, once bool) (Summary, error) {
+	atomic.StoreInt32(&c.running, 1)
+	atomic.StoreInt64(&c.expiries, 0)
+	defer atomic.StoreInt32(&c.running, 0)
+
+	c.resolveLockDuration(ctx)
+	c.resolveMaxDeliveryCount(ctx)
+	c.checkRetryForward(ctx)
+	lockDuration := c.currentLockDuration()
+	c.logf("🔒 Lock duration is %v. Renewing session locks every %v.", lockDuration, lockDuration/2)
+	c.logf("📥 Receiving session messages %v.", c.strategy)
+
+	deadline, disarm := c.startDeadline()
+	defer disarm()
+
+	if c.poolSize > 0 {
+		c.pool = newHandlerPool(c.poolSize)
+		defer c.pool.stop()
+	}
+
+	if c.heartbeatLog > 0 {
+		heartbeatCtx, stopHeartbeats := context.WithCancel(ctx)
+		defer stopHeartbeats()
+		go c.logHeartbeats(heartbeatCtx)
+	}
+
+	if c.latencyLog > 0 {
+		latencyCtx, stopLatencies := context.WithCancel(ctx)
+		defer stopLatencies()
+		go c.summarizeLatencies(latencyCtx)
+	}
+
+	stats := &runStats{start: time.Now()}
+	c.setRunStats(stats)
+	err := c.receiveLoops(ctx, once, stats, deadline)
+	if err != nil && !errors.Is(err, ctx.Err()) {
+		c.recordRunError(err)
+		c.setLastError(err)
+	}
+	c.reportRun(stats, err)
+	summary := stats.summary()
+	if err == nil && c.isStopping() {
+		c.logf("🏁 Stopped after %v. Processed %d messages in %d sessions.", summary.Duration.Round(time.Second), summary.Messages, summary.Sessions)
+	}
+
+	return summary, err
+}
+
+// receiveLoops runs one receive loop per concurrent session and returns the first error of any loop. An error stops
+// the remaining loops as well. With WithAutoScale loops are added on demand and retire on their own.
+func (c *Convoy) receiveLoops(ctx context.Context, once bool, stats *runStats, deadline <-chan struct{}) error {
+	n := int64(c.concurrentSessions)
+	if c.autoScale != nil {
+		n = c.autoScale.min
+	}
+	if n <= 1 && c.autoScale == nil {
+		stats.loops = 1
+		c.metrics.SetGauge(metricConcurrency, 1)
+		return c.receiveLoop(ctx, once, stats, deadline)
+	}
+	stats.loops = n
+	c.metrics.SetGauge(metricConcurrency, float64(n))
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error)
+	running := 0
+	spawn := func() {
+		running++
+		go func() {
+			errc <- c.receiveLoop(loopCtx, once, stats, deadline)
+		}()
+	}
+	for i := int64(0); i < n; i++ {
+		spawn()
+	}
+
+	var up chan struct{}
+	if c.autoScale != nil {
+		up = c.autoScale.up
+	}
+
+	var first error
+	for running > 0 {
+		select {
+		case err := <-errc:
+			running--
+			if err != nil && first == nil {
+				first = err
+				cancel()
+				up = nil
+			}
+		case <-up:
+			if loopCtx.Err() == nil && !c.isStopping() && c.scaleUp(stats) {
+				spawn()
+			}
+		}
+	}
+
+	return first
+}
+
+// receiveLoop accepts and processes one session after another
+func (c *Convoy) receiveLoop(ctx context.Context, once bool, stats *runStats, deadline <-chan struct{}) error {
+	emptyAccepts := 0
+	for {
+		if err := c.awaitMemory(ctx); err != nil {
+			return nil
+		}
+		if c.isStopping() {
+			return nil
+		}
+
+		qs := c.newSession()
+		sess := &StepSessionHandler{
+			convoy: c,
+			stats:  stats,
+		}
+
+		done := make(chan struct{})
+		if !c.synchronous {
+			go c.watch(sess, done, deadline)
+		}
+
+		err := c.receiveOne(ctx, qs, sess)
+		close(done)
+		if ctx.Err() != nil {
+			return c.shutdown(ctx, qs, err)
+		}
+		if c.isStopping() {
+			return c.closeSession(ctx, qs)
+		}
+		if stallErr := c.stalled(); stallErr != nil {
+			c.closeSession(ctx, qs)
+			return stallErr
+		}
+		if err != nil {
+			switch c.acceptDecision(sess, err) {
+			case AcceptRetry:
+				c.logf("➰ Failed to accept session, retrying: %v", err)
+				continue
+			case AcceptBackoff:
+				c.logf("➰ Failed to accept session, retrying in %v: %v", reconnectBackoff, err)
+				if err = sleepCtx(ctx, reconnectBackoff); err != nil {
+					return c.shutdown(ctx, qs, err)
+				}
+				continue
+			case AcceptStop:
+				c.logf("🚫 Failed to accept session, stopping: %v", err)
+				return err
+			}
+
+			// Running out of quota is handled before the classes since it depends on the concurrency
+			if isQuotaExceeded(err) {
+				if left, retired := stats.retireLoop(); retired {
+					if c.autoScale != nil {
+						c.autoScale.limit(left)
+					}
+					c.logf("❗ Quota of the namespace exceeded. Continuing with %d concurrent sessions: %v", left, err)
+					c.metrics.SetGauge(metricConcurrency, float64(left))
+					return c.closeSession(ctx, qs)
+				}
+
+				// The last loop stays, waiting for the quota to free up
+				c.logf("❗ Quota of the namespace exceeded, retrying in %v: %v", serverBusyBackoff, err)
+				if err = sleepCtx(ctx, serverBusyBackoff); err != nil {
+					return c.shutdown(ctx, qs, err)
+				}
+				continue
+			}
+
+			switch c.classify(err) {
+			case ErrorTimeout:
+				emptyAccepts++
+				c.acceptTimedOut()
+				if once && emptyAccepts >= c.emptyAccepts {
+					c.logf("🏁 No session available for %d consecutive attempts. Queue drained.", emptyAccepts)
+					return c.closeSession(ctx, qs)
+				}
+				if c.autoScale != nil {
+					c.autoScale.timedOut()
+					if c.scaleDown(stats, emptyAccepts) {
+						return c.closeSession(ctx, qs)
+					}
+				}
+
+				c.logf("➰ Timeout waiting for messages. Entering next loop.")
+				continue
+			case ErrorThrottle:
+				if err = c.throttled(ctx, err); err != nil {
+					return c.shutdown(ctx, qs, err)
+				}
+				continue
+			case ErrorConnection:
+				c.logf("❗ Connection to namespace failed, reconnecting in %v: %v", reconnectBackoff, err)
+				stats.addReconnect()
+				c.emit(Event{Kind: EventReconnect, Err: err})
+				if err = sleepCtx(ctx, reconnectBackoff); err != nil {
+					return c.shutdown(ctx, qs, err)
+				}
+				continue
+			case ErrorFatal:
+				if isEntityUnavailable(err) {
+					c.logf("🚫 FATAL: queue is not available, it may have been deleted or disabled: %v", err)
+					return fmt.Errorf("%w: %v", ErrEntityUnavailable, err)
+				}
+				c.logf("🚫 FATAL: %v", err)
+				return err
+			}
+
+			if c.stickySession != "" {
+				c.logf("❗ Lost sticky session %s, accepting it again in %v: %v", c.stickySession, stickyRetryBackoff, err)
+				if err = sleepCtx(ctx, stickyRetryBackoff); err != nil {
+					return c.shutdown(ctx, qs, err)
+				}
+				continue
+			}
+
+			return err
+		}
+
+		emptyAccepts = 0
+		c.sessionAccepted()
+		if c.autoScale != nil {
+			c.autoScale.accepted()
+		}
+		if err = c.closeSession(ctx, qs); err != nil {
+			return err
+		}
+	}
+}
+
+// receiveOne processes one session and drains it once ctx is cancelled. Handlers and settlements run on a context that
+// is not cancelled with ctx, so the message in flight is still handled and settled; only the hard shutdown timeout
+// cancels it.
+func (c *Convoy) receiveOne(ctx context.Context, qs sessionReceiver, sess *StepSessionHandler) error {
+	workCtx, forceStop := context.WithCancel(detachedContext{parent: ctx})
+	defer forceStop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.receiveSession(workCtx, qs, sess)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	start := time.Now()
+	c.logf("🛑 Shutdown requested. Stopped accepting sessions.")
+	sess.drain()
+	if sess.session() == nil {
+		// No session was accepted yet, so there is nothing to drain and the accept is abandoned along with its error
+		forceStop()
+		<-errc
+		return ctx.Err()
+	}
+	c.logf("🛑 Draining current session.")
+	drained := func(err error) error {
+		c.logf("🛑 Drained current session in %v.", time.Since(start))
+		return err
+	}
+
+	if c.hardShutdownTimeout <= 0 {
+		return drained(<-errc)
+	}
+
+	timer := time.NewTimer(c.hardShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-errc:
+		return drained(err)
+	case <-timer.C:
+		c.logf("❗ Handler did not finish within %v of shutdown. Forcing session close.", c.hardShutdownTimeout)
+		c.metrics.IncCounter(metricForcedTerminations)
+		// Cancelling the handler and closing the session unblock ReceiveOne, which then exits into the buffered channel
+		forceStop()
+		sess.release()
+		return ErrHardShutdown
+	}
+}
+
+// shutdown closes the session receiver once the context of Run is cancelled and returns the reason Run stopped. Errors
+// caused by the cancellation itself, e.g. of a settlement cut short, are part of the graceful shutdown and dropped, as
+// is an accept that timed out while the shutdown was requested.
+func (c *Convoy) shutdown(ctx context.Context, qs sessionReceiver, err error) error {
+	if closeErr := c.closeSession(context.Background(), qs); closeErr != nil {
+		c.logf("❗ Failed to close session receiver: %v", closeErr)
+	} else {
+		c.logf("🛑 Closed session receiver.")
+	}
+
+	c.logf("🛑 Run stopped.")
+	if err == nil || errors.Is(err, ctx.Err()) || c.classify(err) == ErrorTimeout {
+		return nil
+	}
+	return err
+}
+
+// Close releases the queue and flushes the audit sink
+func (c *Convoy) Close(ctx context.Context) error {
+	err := c.source.close(ctx)
+	if err != nil {
+		c.logf("❗ Failed to close queue client: %v", err)
+	} else {
+		c.logf("🛑 Closed queue client.")
+	}
+
+	if c.forward != nil {
+		if fwdErr := c.forward.sender.Close(ctx); fwdErr != nil {
+			c.logf("❗ Failed to close dead-letter forward client: %v", fwdErr)
+			if err == nil {
+				err = fwdErr
+			}
+		}
+	}
+
+	if replyErr := c.replies.close(ctx); replyErr != nil {
+		c.logf("❗ Failed to close reply clients: %v", replyErr)
+		if err == nil {
+			err = replyErr
+		}
+	}
+
+	if c.isolated != nil {
+		c.isolated.stop()
+	}
+	if c.events != nil {
+		c.events.stop()
+	}
+
+	if c.retry != nil {
+		if retryErr := c.retry.sender.Close(ctx); retryErr != nil {
+			c.logf("❗ Failed to close retry queue client: %v", retryErr)
+			if err == nil {
+				err = retryErr
+			}
+		}
+	}
+
+	if c.audit != nil {
+		if auditErr := c.audit.Close(); auditErr != nil {
+			c.logf("❗ Failed to flush audit sink: %v", auditErr)
+			if err == nil {
+				err = auditErr
+			}
+		} else {
+			c.logf("🛑 Flushed audit sink.")
+		}
+	}
+
+	c.logf("🛑 Shutdown complete.")
+	return err
+}
+
+// watch is a recurring routine to check whether message handler is processing messages in session. Instead of
+// polling it sleeps until the idle deadline of the session, so an idle session is closed as soon as the idle timeout
+// has passed.
+func (c *Convoy) watch(sess *StepSessionHandler, done, deadline <-chan struct{}) {
+	timer := time.NewTimer(c.watchdogInterval)
+	defer timer.Stop()
+
+	for {
+		var now time.Time
+		select {
+		case now = <-timer.C:
+		case <-deadline:
+			sess.stopAfterCurrent()
+			deadline = nil
+			continue
+		case <-done:
+			return
+		}
+
+		if c.checkSession(sess, now) {
+			return
+		}
+		timer.Reset(c.nextCheck(sess))
+	}
+}
+
+// nextCheck returns the wait until the next watchdog check of the session: until its idle deadline while it is active,
+// and the watchdog interval while it has not started yet, is confirmed stale or has a hung handler
+func (c *Convoy) nextCheck(sess *StepSessionHandler) time.Duration {
+	if sess.session() == nil || sess.staleChecks > 0 {
+		return c.watchdogInterval
+	}
+	if remaining := c.idleTimeout + c.expiryGrace - sess.idleFor(); remaining > 0 {
+		return remaining
+	}
+	return c.watchdogInterval
+}
+
+// checkSession performs a single watchdog check of the session and reports whether the session was closed. Idleness is
+// measured on the monotonic clock; now is only used for logging.
+func (c *Convoy) checkSession(sess *StepSessionHandler, now time.Time) bool {
+	ms := sess.session()
+	if ms == nil {
+		c.logf("❗ Waiting to start new session at %v", now)
+		return false
+	}
+
+	c.logf("# Checking timestamp of the last processed message in session at %v", now)
+	if sess.idleFor() < c.idleTimeout+c.expiryGrace {
+		sess.staleChecks = 0
+		c.logf("✔ Session is active.")
+		return false
+	}
+	if sess.staleChecks++; sess.staleChecks < c.expiryConfirmations {
+		c.logf("# Session stale on %d of %d consecutive checks.", sess.staleChecks, c.expiryConfirmations)
+		return false
+	}
+	sess.staleChecks = 0
+
+	// A handler that is still running without a heartbeat is hung; the session itself is not idle
+	if since, processing := sess.processingSince(); processing {
+		if sess.cancelRunning(ErrAbandon) {
+			c.logf("⏳ Handler hung for %v without a heartbeat. Cancelling it and abandoning its message.", time.Since(since).Round(time.Second))
+		}
+		return false
+	}
+
+	if c.stickySession != "" {
+		c.logf("✔ Sticky session is idle. Keeping it.")
+		return false
+	}
+
+	c.logf("❌ Session idle. Closing it now.")
+	sess.release()
+	sess.stats.addExpiry()
+	c.emit(Event{Kind: EventSessionExpired, SessionID: sess.currentSessionID()})
+	c.sessionExpired()
+	return true
+}
@@ -0,0 +1,124 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-amqp"
+)
+
+func TestRunOnceCompletesSessionsInOrder(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2", "3")
+	broker.add("b", "1", "2")
+
+	var mu sync.Mutex
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		mu.Lock()
+		handled = append(handled, msg.ID)
+		mu.Unlock()
+		return nil
+	})
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if summary.Messages != 5 || summary.Sessions != 2 {
+		t.Errorf("summary = %d messages in %d sessions, want 5 in 2", summary.Messages, summary.Sessions)
+	}
+	if want := []string{"a-1", "a-2", "a-3", "b-1", "b-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if n := broker.remaining(); n != 0 {
+		t.Errorf("%d messages left on the broker", n)
+	}
+}
+
+func TestRunOnceRedeliversAbandonedMessageFirst(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		if msg.ID == "a-1" && msg.DeliveryCount == 1 {
+			return ErrAbandon
+		}
+		return nil
+	})
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if want := []string{"a-1", "a-1", "a-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+
+	want := []Outcome{OutcomeAbandoned, OutcomeCompleted, OutcomeCompleted}
+	var got []Outcome
+	for _, s := range broker.settlements() {
+		got = append(got, s.outcome)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+}
+
+func TestRunOnceStopsOnHandlerError(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+
+	failure := errors.New("boom")
+	c := newTestConvoy(t, broker, func(context.Context, *servicebus.Message) error {
+		return failure
+	})
+
+	if _, err := c.RunOnce(context.Background()); !errors.Is(err, failure) {
+		t.Fatalf("RunOnce = %v, want %v", err, failure)
+	}
+}
+
+func TestNewRequiresHandler(t *testing.T) {
+	const connStr = "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=secret"
+
+	if _, err := New(connStr, "queue", nil); err == nil || !strings.Contains(err.Error(), "handler") {
+		t.Errorf("New without handler = %v, want an error about the handler", err)
+	}
+	if _, err := NewProcessor(connStr, "queue", nil); err == nil || !strings.Contains(err.Error(), "handler") {
+		t.Errorf("NewProcessor without handler = %v, want an error about the handler", err)
+	}
+
+	stream := func(context.Context, io.Reader, *servicebus.Message) error { return nil }
+	if _, err := newConvoy(nil, []Option{WithStreamHandler(stream)}); err != nil {
+		t.Errorf("newConvoy with a stream handler = %v, want nil", err)
+	}
+}
+
+func TestRunStopsWhenEntityUnavailable(t *testing.T) {
+	for _, cond := range []amqp.ErrorCondition{conditionNotFound, conditionEntityDisabled} {
+		broker := newFakeBroker()
+		broker.add("a", "1")
+		receives := 0
+		broker.receiveErr = func() error {
+			receives++
+			return &amqp.Error{Condition: cond, Description: "entity fake could not be found"}
+		}
+		c := newTestConvoy(t, broker, nopHandler)
+
+		err := c.Run(context.Background())
+		if !errors.Is(err, ErrEntityUnavailable) {
+			t.Errorf("Run with %s = %v, want %v", cond, err, ErrEntityUnavailable)
+		}
+		if receives != 1 {
+			t.Errorf("Run with %s received %d times, want no retries", cond, receives)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+type correlationKey struct{}
+
+// WithCorrelationProperty correlates the log lines of a message by the value of its application property name.
+// Messages without the property, or all messages when the option is not set, are correlated by sequence number.
+func WithCorrelationProperty(name string) Option {
+	return func(c *Convoy) {
+		c.correlationProperty = name
+	}
+}
+
+// CorrelationID returns the correlation value of the message handled with ctx, to group the handler's own log lines
+// with those of the convoy for the same message
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// withCorrelation stores the correlation value of msg in ctx
+func (c *Convoy) withCorrelation(ctx context.Context, msg *servicebus.Message) context.Context {
+	if c.correlationProperty != "" {
+		if v, ok := msg.UserProperties[c.correlationProperty]; ok && v != nil {
+			return context.WithValue(ctx, correlationKey{}, fmt.Sprint(v))
+		}
+	}
+
+	var seq string
+	if msg.SystemProperties != nil && msg.SystemProperties.SequenceNumber != nil {
+		seq = strconv.FormatInt(*msg.SystemProperties.SequenceNumber, 10)
+	}
+	return context.WithValue(ctx, correlationKey{}, seq)
+}
+
+// msgLogf writes a log line about the message handled with ctx, tagged with its correlation value
+func (c *Convoy) msgLogf(ctx context.Context, format string, v ...interface{}) {
+	c.logf("[%s] "+format, append([]interface{}{CorrelationID(ctx)}, v...)...)
+}
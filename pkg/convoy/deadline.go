@@ -0,0 +1,47 @@
+package convoy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithMaxRunDuration stops the convoy gracefully once it has run for d, regardless of the work remaining, e.g. to
+// time-box a batch run. When the deadline is reached the convoy stops accepting sessions, lets the handler of the
+// current message finish and settles it, releases the session and returns from Run or RunOnce without error. Unlike
+// cancelling the context of Run, the deadline never cancels a handler that is in flight.
+func WithMaxRunDuration(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.maxRunDuration = d
+	}
+}
+
+// startDeadline arms the maximum run duration and returns the channel closed when it is reached, which is nil if no
+// maximum is set, and a function that disarms it
+func (c *Convoy) startDeadline() (<-chan struct{}, func()) {
+	atomic.StoreInt32(&c.stopping, 0)
+	if c.maxRunDuration <= 0 {
+		return nil, func() {}
+	}
+
+	reached := make(chan struct{})
+	timer := time.AfterFunc(c.maxRunDuration, func() {
+		atomic.StoreInt32(&c.stopping, 1)
+		c.logf("⏱ Maximum run duration of %v reached. Finishing current message.", c.maxRunDuration)
+		close(reached)
+	})
+	return reached, func() { timer.Stop() }
+}
+
+// isStopping reports whether the maximum run duration was reached
+func (c *Convoy) isStopping() bool {
+	return atomic.LoadInt32(&c.stopping) == 1
+}
+
+// stopAfterCurrent releases the session right away if no handler is running. Otherwise the session is released once
+// the message of the running handler is settled.
+func (sh *StepSessionHandler) stopAfterCurrent() {
+	if _, processing := sh.processingSince(); processing || sh.session() == nil {
+		return
+	}
+	sh.release()
+}
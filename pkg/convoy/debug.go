@@ -0,0 +1,235 @@
+package convoy
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// recentEventsSize bounds the events kept for DebugHandler
+const recentEventsSize = 100
+
+// redactConnectionString replaces the credentials of connStr so that it can be shown to operators
+func redactConnectionString(connStr string) string {
+	parts := strings.Split(connStr, ";")
+	for i, part := range parts {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "sharedaccesskey", "sharedaccesssignature":
+			parts[i] = kv[0] + "=REDACTED"
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// eventRing keeps the most recent events of the convoy
+type eventRing struct {
+	sync.Mutex
+	events []Event
+	next   int
+}
+
+// add records e, overwriting the oldest event once the ring is full
+func (r *eventRing) add(e Event) {
+	r.Lock()
+	defer r.Unlock()
+
+	if len(r.events) < recentEventsSize {
+		r.events = append(r.events, e)
+		return
+	}
+	r.events[r.next] = e
+	r.next = (r.next + 1) % recentEventsSize
+}
+
+// snapshot returns the recorded events, oldest first
+func (r *eventRing) snapshot() []Event {
+	r.Lock()
+	defer r.Unlock()
+
+	out := make([]Event, 0, len(r.events))
+	out = append(out, r.events[r.next:]...)
+	return append(out, r.events[:r.next]...)
+}
+
+// trackSession registers sh as active, or unregisters it, for DebugHandler
+func (c *Convoy) trackSession(sh *StepSessionHandler, active bool) {
+	if active {
+		c.sessions.Store(sh, struct{}{})
+	} else {
+		c.sessions.Delete(sh)
+	}
+}
+
+// setRunStats makes stats the counters reported by DebugHandler
+func (c *Convoy) setRunStats(stats *runStats) {
+	c.debugMu.Lock()
+	c.runStats = stats
+	c.debugMu.Unlock()
+}
+
+// setLastError records err as the last error that stopped a run
+func (c *Convoy) setLastError(err error) {
+	c.debugMu.Lock()
+	c.lastErr = err
+	c.lastErrAt = time.Now()
+	c.debugMu.Unlock()
+}
+
+type debugSession struct {
+	SessionID string `json:"session_id"`
+	StartedAt string `json:"started_at"`
+	HeldFor   string `json:"held_for"`
+	Processed int    `json:"processed"`
+}
+
+type debugEvent struct {
+	Kind           EventKind `json:"kind"`
+	Time           string    `json:"time"`
+	SessionID      string    `json:"session_id,omitempty"`
+	SequenceNumber int64     `json:"sequence_number,omitempty"`
+	CorrelationID  string    `json:"correlation_id,omitempty"`
+	Outcome        Outcome   `json:"outcome,omitempty"`
+	Latency        string    `json:"latency,omitempty"`
+	Err            string    `json:"error,omitempty"`
+}
+
+type debugStats struct {
+	Started      string `json:"started"`
+	Duration     string `json:"duration"`
+	Sessions     int64  `json:"sessions"`
+	Messages     int64  `json:"messages"`
+	Completed    int64  `json:"completed"`
+	Abandoned    int64  `json:"abandoned"`
+	DeadLettered int64  `json:"dead_lettered"`
+	Deferred     int64  `json:"deferred"`
+	Expiries     int64  `json:"expiries"`
+	Reconnects   int64  `json:"reconnects"`
+	Concurrency  int64  `json:"concurrency"`
+	HoldTime     string `json:"hold_time"`
+	MaxHoldTime  string `json:"max_hold_time"`
+}
+
+type debugError struct {
+	Error string `json:"error"`
+	Time  string `json:"time"`
+}
+
+// debugSessions returns the sessions being processed, longest held first
+func (c *Convoy) debugSessions() []debugSession {
+	out := []debugSession{}
+	c.sessions.Range(func(k, _ interface{}) bool {
+		sh := k.(*StepSessionHandler)
+		sh.RLock()
+		s := debugSession{
+			SessionID: sh.sessionID,
+			StartedAt: sh.startedAt.Format(time.RFC3339),
+			HeldFor:   time.Since(sh.startedAt).Round(time.Millisecond).String(),
+			Processed: sh.processed,
+		}
+		started := sh.startedAt
+		sh.RUnlock()
+		if started.IsZero() {
+			return true
+		}
+		out = append(out, s)
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt < out[j].StartedAt })
+	return out
+}
+
+// debugEvents returns the recent events in their JSON form
+func (c *Convoy) debugEvents() []debugEvent {
+	events := c.recent.snapshot()
+	out := make([]debugEvent, 0, len(events))
+	for _, e := range events {
+		d := debugEvent{
+			Kind:           e.Kind,
+			Time:           e.Time.Format(time.RFC3339Nano),
+			SessionID:      e.SessionID,
+			SequenceNumber: e.SequenceNumber,
+			CorrelationID:  e.CorrelationID,
+			Outcome:        e.Outcome,
+		}
+		if e.Latency > 0 {
+			d.Latency = e.Latency.String()
+		}
+		if e.Err != nil {
+			d.Err = e.Err.Error()
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// debugState returns the counters of the current or last run and the last error that stopped a run
+func (c *Convoy) debugState() (*debugStats, *debugError) {
+	c.debugMu.Lock()
+	stats, lastErr, lastErrAt := c.runStats, c.lastErr, c.lastErrAt
+	c.debugMu.Unlock()
+
+	var ds *debugStats
+	if stats != nil {
+		r := stats.report(nil)
+		ds = &debugStats{
+			Started:      stats.start.Format(time.RFC3339),
+			Duration:     r.Duration.Round(time.Second).String(),
+			Sessions:     r.Sessions,
+			Messages:     r.Messages,
+			Completed:    r.Completed,
+			Abandoned:    r.Abandoned,
+			DeadLettered: r.DeadLettered,
+			Deferred:     r.Deferred,
+			Expiries:     r.Expiries,
+			Reconnects:   r.Reconnects,
+			Concurrency:  r.Concurrency,
+			HoldTime:     r.HoldTime.Round(time.Millisecond).String(),
+			MaxHoldTime:  r.MaxHoldTime.Round(time.Millisecond).String(),
+		}
+	}
+	var de *debugError
+	if lastErr != nil {
+		de = &debugError{Error: lastErr.Error(), Time: lastErrAt.Format(time.RFC3339)}
+	}
+	return ds, de
+}
+
+// DebugHandler serves the state of the convoy as JSON for operators, e.g. at /debug/convoy next to HealthHandler: its
+// effective configuration, the connection string with its credentials redacted, the sessions being processed, the
+// last 100 lifecycle events, the last error that stopped a run and the counters of the current or last run. All of it
+// is read from snapshots taken under the convoy's locks, so the handler is safe to call while the convoy is running.
+func (c *Convoy) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats, lastErr := c.debugState()
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(struct {
+			Running          bool           `json:"running"`
+			Shedding         bool           `json:"shedding"`
+			ConnectionString string         `json:"connection_string,omitempty"`
+			Config           Settings       `json:"config"`
+			ActiveSessions   []debugSession `json:"active_sessions"`
+			RecentEvents     []debugEvent   `json:"recent_events"`
+			LastError        *debugError    `json:"last_error,omitempty"`
+			Stats            *debugStats    `json:"stats,omitempty"`
+		}{
+			Running:          atomic.LoadInt32(&c.running) == 1,
+			Shedding:         c.isShedding(),
+			ConnectionString: c.redactedConnStr,
+			Config:           c.Config(),
+			ActiveSessions:   c.debugSessions(),
+			RecentEvents:     c.debugEvents(),
+			LastError:        lastErr,
+			Stats:            stats,
+		})
+	})
+}
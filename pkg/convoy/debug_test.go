@@ -0,0 +1,48 @@
+package convoy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactConnectionString(t *testing.T) {
+	const secret = "c2VjcmV0a2V5PT0="
+	tests := []struct {
+		name    string
+		connStr string
+		want    string
+	}{
+		{
+			name:    "shared access key",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=" + secret,
+			want:    "Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=REDACTED",
+		},
+		{
+			name:    "key in other case with spaces",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/; sharedaccesskey =" + secret + ";SharedAccessKeyName=listen",
+			want:    "Endpoint=sb://example.servicebus.windows.net/; sharedaccesskey =REDACTED;SharedAccessKeyName=listen",
+		},
+		{
+			name:    "shared access signature",
+			connStr: "Endpoint=sb://example.servicebus.windows.net/;SharedAccessSignature=SharedAccessSignature sr=x&sig=" + secret,
+			want:    "Endpoint=sb://example.servicebus.windows.net/;SharedAccessSignature=REDACTED",
+		},
+		{
+			name:    "repeated key",
+			connStr: "SharedAccessKey=" + secret + ";Endpoint=sb://example.servicebus.windows.net/;SharedAccessKey=" + secret,
+			want:    "SharedAccessKey=REDACTED;Endpoint=sb://example.servicebus.windows.net/;SharedAccessKey=REDACTED",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := redactConnectionString(tt.connStr)
+			if strings.Contains(got, secret) {
+				t.Fatalf("redactConnectionString leaks the secret: %q", got)
+			}
+			if got != tt.want {
+				t.Errorf("redactConnectionString = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
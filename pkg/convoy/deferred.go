@@ -0,0 +1,119 @@
+package convoy
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// deferredSet tracks the sequence numbers of the messages deferred per session
+type deferredSet struct {
+	sync.Mutex
+	bySession map[string]map[int64]struct{}
+}
+
+func (d *deferredSet) add(sessionID string, seq int64) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.bySession == nil {
+		d.bySession = make(map[string]map[int64]struct{})
+	}
+	if d.bySession[sessionID] == nil {
+		d.bySession[sessionID] = make(map[int64]struct{})
+	}
+	d.bySession[sessionID][seq] = struct{}{}
+}
+
+func (d *deferredSet) remove(sessionID string, seq int64) {
+	d.Lock()
+	defer d.Unlock()
+
+	delete(d.bySession[sessionID], seq)
+	if len(d.bySession[sessionID]) == 0 {
+		delete(d.bySession, sessionID)
+	}
+}
+
+func (d *deferredSet) list(sessionID string) []int64 {
+	d.Lock()
+	defer d.Unlock()
+
+	seqs := make([]int64, 0, len(d.bySession[sessionID]))
+	for seq := range d.bySession[sessionID] {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
+
+// Deferred returns the sequence numbers of the messages of session sessionID that this convoy deferred and that have
+// not been received again with ReceiveDeferred, in ascending order. Messages deferred by other receivers or before the
+// convoy started are not known to it.
+func (c *Convoy) Deferred(sessionID string) []int64 {
+	return c.deferred.list(sessionID)
+}
+
+// ReceiveDeferred fetches the deferred messages with the given sequence numbers from the session handled with ctx
+// and processes them with handler one at a time, in the order of sequenceNumbers. It must be called from the handler
+// of a message of that session, since deferred messages can only be received while their session is locked. Each
+// message is settled by the error handler returns, like any other message, and may be deferred again. Deferred
+// messages are processed out of their original order by design: the session moved on when they were deferred, and
+// they run now, between the messages around the handler that calls ReceiveDeferred.
+func (c *Convoy) ReceiveDeferred(ctx context.Context, sequenceNumbers []int64, handler HandlerFunc) error {
+	ms, ok := ctx.Value(sessionKey{}).(lockedSession)
+	if !ok || ms == nil || ms.SessionID() == nil {
+		return ErrNoSession
+	}
+	sessionID := *ms.SessionID()
+
+	var msgs []*servicebus.Message
+	collect := servicebus.HandlerFunc(func(ctx context.Context, msg *servicebus.Message) error {
+		msgs = append(msgs, msg)
+		return nil
+	})
+	qs := c.source.newSession(&sessionID, 0)
+	defer qs.Close(context.Background())
+	if err := qs.ReceiveDeferred(ctx, collect, servicebus.PeekLockMode, sequenceNumbers...); err != nil {
+		return err
+	}
+
+	rank := make(map[int64]int, len(sequenceNumbers))
+	for i, seq := range sequenceNumbers {
+		rank[seq] = i
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return rank[sequenceOf(msgs[i])] < rank[sequenceOf(msgs[j])] })
+
+	for _, msg := range msgs {
+		st := settlementFor(handler(ctx, msg))
+		if st.outcome == OutcomeDeadLettered {
+			st.properties = c.deadLetterProperties(ctx, msg)
+		}
+		if err := c.source.settle(ctx, msg, st); err != nil {
+			return err
+		}
+		if st.outcome != OutcomeDeferred {
+			c.deferred.remove(sessionID, sequenceOf(msg))
+		}
+		if c.audit != nil {
+			c.audit.Record(newAuditRecord(msg, st.outcome))
+		}
+		if st.err != nil {
+			return st.err
+		}
+	}
+
+	return nil
+}
+
+// sequenceOf returns the sequence number of msg or 0 if it has none. The sequence number is assigned by the broker
+// when the message is accepted and is the authoritative order of a session; wherever the convoy orders or checks the
+// order of messages it compares sequence numbers, never enqueued times, which several messages may share.
+func sequenceOf(msg *servicebus.Message) int64 {
+	if msg.SystemProperties == nil || msg.SystemProperties.SequenceNumber == nil {
+		return 0
+	}
+	return *msg.SystemProperties.SequenceNumber
+}
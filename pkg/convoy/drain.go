@@ -0,0 +1,53 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionUnavailable is returned by DrainSession when the session cannot be accepted, e.g. because it is locked by
+// another receiver or has no messages
+var ErrSessionUnavailable = errors.New("session could not be accepted")
+
+// DrainSession accepts the session sessionID and processes its available messages in order with handler until the
+// session is idle for the idle timeout, then releases it and returns the number of messages settled. Messages are
+// ordered and settled exactly as by Run, but the session is processed regardless of sharding and circuit breakers,
+// which suits reprocessing a single stuck convoy from operational tooling. DrainSession may run alongside Run.
+func (c *Convoy) DrainSession(ctx context.Context, sessionID string, handler HandlerFunc) (int, error) {
+	if sessionID == "" {
+		return 0, ErrMissingSessionID
+	}
+	c.resolveLockDuration(ctx)
+	c.resolveMaxDeliveryCount(ctx)
+
+	qs := c.source.newSession(&sessionID, 0)
+	sess := &StepSessionHandler{
+		convoy:   c,
+		stats:    &runStats{start: time.Now()},
+		handler:  handler,
+		targeted: true,
+	}
+
+	done := make(chan struct{})
+	if !c.synchronous {
+		go c.watch(sess, done, nil)
+	}
+	err := c.receiveOne(ctx, qs, sess)
+	close(done)
+
+	processed := sess.processedCount()
+	if closeErr := c.closeSession(context.Background(), qs); closeErr != nil {
+		c.logf("❗ Failed to close session receiver: %v", closeErr)
+	}
+	if err != nil && sess.session() == nil {
+		return 0, fmt.Errorf("%w: %s: %v", ErrSessionUnavailable, sessionID, err)
+	}
+	if ctx.Err() != nil {
+		return processed, ctx.Err()
+	}
+
+	c.logf("🏁 Drained session %s. Processed %d messages.", sessionID, processed)
+	return processed, err
+}
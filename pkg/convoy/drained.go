@@ -0,0 +1,44 @@
+package convoy
+
+import (
+	"sync/atomic"
+)
+
+// WithOnDrained calls fn when the queue appears drained while Run keeps going: the number of consecutive accept
+// attempts set with WithEmptyAccepts timed out without any session being accepted in between, counted across all
+// concurrent receive loops. fn fires once per drain and again only after a session was accepted and the queue drained
+// anew, e.g. to trigger a downstream step or scale the worker to zero. Unlike RunOnce the convoy keeps waiting for
+// sessions. fn is called from a receive loop and should return quickly.
+func WithOnDrained(fn func()) Option {
+	return func(c *Convoy) {
+		c.onDrained = fn
+	}
+}
+
+// acceptTimedOut counts an accept attempt that timed out and reports the drain once the threshold is reached
+func (c *Convoy) acceptTimedOut() {
+	if c.onDrained == nil {
+		return
+	}
+	if atomic.AddInt64(&c.emptyStreak, 1) < int64(c.emptyAccepts) || !atomic.CompareAndSwapInt32(&c.drained, 0, 1) {
+		return
+	}
+	c.logf("🏁 No session available for %d consecutive attempts. Queue drained.", c.emptyAccepts)
+	c.notifyDrained()
+}
+
+// notifyDrained calls the drain callback on its receive loop, recovering a panic which is logged by recoverPanic
+func (c *Convoy) notifyDrained() (err error) {
+	defer c.recoverPanic(ErrHandlerPanic, "notifying drain", &err)
+	c.onDrained()
+	return nil
+}
+
+// sessionAccepted re-arms the drain notification once activity resumes
+func (c *Convoy) sessionAccepted() {
+	if c.onDrained == nil {
+		return
+	}
+	atomic.StoreInt64(&c.emptyStreak, 0)
+	atomic.StoreInt32(&c.drained, 0)
+}
@@ -0,0 +1,38 @@
+package convoy
+
+import (
+	"time"
+)
+
+// defaultEmptySessionTimeout is how long an accepted session may go without its first message
+const defaultEmptySessionTimeout = 5 * time.Second
+
+// WithEmptySessionTimeout releases an accepted session if its first message does not arrive within d, instead of
+// holding it until the idle timeout. This happens when all messages of the session are deferred or scheduled for
+// later, and releasing it quickly lets other receivers and sessions move on. A negative d disables the check.
+func WithEmptySessionTimeout(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.emptySessionTimeout = d
+	}
+}
+
+// watchEmpty arms the release of the session if it delivers no message in time
+func (sh *StepSessionHandler) watchEmpty() *time.Timer {
+	d := sh.convoy.emptySessionTimeout
+	if d <= 0 || sh.convoy.synchronous || sh.convoy.stickySession != "" {
+		return nil
+	}
+
+	ms := sh.messageSession
+	return time.AfterFunc(d, func() {
+		sh.RLock()
+		empty := !sh.received && !sh.ended && sh.messageSession == ms
+		sh.RUnlock()
+		if !empty {
+			return
+		}
+
+		sh.convoy.logf("🕳 Session accepted without messages for %v. Releasing it.", d)
+		sh.release()
+	})
+}
@@ -0,0 +1,187 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithTopicSubscription receives the sessions of subscription of topic instead of a queue. Pass an empty queue name
+// to New. The session receive loop, settlement and watchdog work as they do for a queue; Send and SendAll publish to
+// the topic, so that the messages reach every subscription whose rules match, this one included.
+func WithTopicSubscription(topic, subscription string) Option {
+	return func(c *Convoy) {
+		c.topicName = topic
+		c.subscriptionName = subscription
+	}
+}
+
+// sessionReceiver receives the messages of one session, either of a queue or of a subscription
+type sessionReceiver interface {
+	ReceiveOne(ctx context.Context, handler servicebus.SessionHandler) error
+	ReceiveDeferred(ctx context.Context, handler servicebus.Handler, mode servicebus.ReceiveMode, sequenceNumbers ...int64) error
+	Close(ctx context.Context) error
+}
+
+// lockedSession is a session locked by the receiver, a *servicebus.MessageSession outside of tests
+type lockedSession interface {
+	SessionID() *string
+	LockedUntil() time.Time
+	RenewLock(ctx context.Context) error
+	State(ctx context.Context) ([]byte, error)
+	SetState(ctx context.Context, state []byte) error
+	Close()
+}
+
+// entityDescription holds the properties of the source entity the convoy reads through the management API
+type entityDescription struct {
+	LockDuration               *string
+	MaxDeliveryCount           *int32
+	RequiresDuplicateDetection *bool
+}
+
+// entity is the source of the sessions of a convoy: a queue or a topic subscription
+type entity interface {
+	// newSession creates the receiver of session sessionID, or of the next available session if it is nil, holding up
+	// to prefetch messages if prefetch is above one
+	newSession(sessionID *string, prefetch uint32) sessionReceiver
+	send(ctx context.Context, msg *servicebus.Message) error
+	describe(ctx context.Context) (entityDescription, error)
+	// settle applies st to msg, a message received from the entity
+	settle(ctx context.Context, msg *servicebus.Message, st settlement) error
+	close(ctx context.Context) error
+}
+
+// queueEntity is a session enabled queue
+type queueEntity struct {
+	ns    *servicebus.Namespace
+	queue *servicebus.Queue
+	name  string
+}
+
+func (e *queueEntity) newSession(sessionID *string, prefetch uint32) sessionReceiver {
+	if prefetch <= 1 {
+		return e.queue.NewSession(sessionID)
+	}
+	return servicebus.NewQueueSession(prefetchQueue{Queue: e.queue, prefetch: prefetch}, sessionID)
+}
+
+func (e *queueEntity) send(ctx context.Context, msg *servicebus.Message) error {
+	return e.queue.Send(ctx, msg)
+}
+
+func (e *queueEntity) describe(ctx context.Context) (entityDescription, error) {
+	qe, err := e.ns.NewQueueManager().Get(ctx, e.name)
+	if err != nil {
+		return entityDescription{}, err
+	}
+	return entityDescription{
+		LockDuration:               qe.LockDuration,
+		MaxDeliveryCount:           qe.MaxDeliveryCount,
+		RequiresDuplicateDetection: qe.RequiresDuplicateDetection,
+	}, nil
+}
+
+func (e *queueEntity) settle(ctx context.Context, msg *servicebus.Message, st settlement) error {
+	return st.apply(ctx, msg)
+}
+
+func (e *queueEntity) close(ctx context.Context) error {
+	return e.queue.Close(ctx)
+}
+
+// subscriptionEntity is a session enabled subscription of a topic. Messages are sent to the topic.
+type subscriptionEntity struct {
+	ns           *servicebus.Namespace
+	topic        *servicebus.Topic
+	subscription *servicebus.Subscription
+	topicName    string
+	name         string
+}
+
+func (e *subscriptionEntity) newSession(sessionID *string, prefetch uint32) sessionReceiver {
+	if prefetch <= 1 {
+		return e.subscription.NewSession(sessionID)
+	}
+	return servicebus.NewSubscriptionSession(prefetchSubscription{Subscription: e.subscription, prefetch: prefetch}, sessionID)
+}
+
+func (e *subscriptionEntity) send(ctx context.Context, msg *servicebus.Message) error {
+	return e.topic.Send(ctx, msg)
+}
+
+// describe combines the lock duration and maximum delivery count of the subscription with the duplicate detection of
+// the topic, which is where duplicates of sent messages are detected
+func (e *subscriptionEntity) describe(ctx context.Context) (entityDescription, error) {
+	sm, err := e.ns.NewSubscriptionManager(e.topicName)
+	if err != nil {
+		return entityDescription{}, err
+	}
+	se, err := sm.Get(ctx, e.name)
+	if err != nil {
+		return entityDescription{}, err
+	}
+	d := entityDescription{LockDuration: se.LockDuration, MaxDeliveryCount: se.MaxDeliveryCount}
+
+	te, err := e.ns.NewTopicManager().Get(ctx, e.topicName)
+	if err != nil {
+		return d, err
+	}
+	d.RequiresDuplicateDetection = te.RequiresDuplicateDetection
+	return d, nil
+}
+
+func (e *subscriptionEntity) settle(ctx context.Context, msg *servicebus.Message, st settlement) error {
+	return st.apply(ctx, msg)
+}
+
+func (e *subscriptionEntity) close(ctx context.Context) error {
+	return firstErr(e.subscription.Close(ctx), e.topic.Close(ctx))
+}
+
+// prefetchSubscription creates the session receivers of a subscription with a prefetch count, see prefetchQueue
+type prefetchSubscription struct {
+	*servicebus.Subscription
+	prefetch uint32
+}
+
+// NewReceiver creates a receiver that holds up to the prefetch count of messages
+func (s prefetchSubscription) NewReceiver(ctx context.Context, opts ...servicebus.ReceiverOption) (*servicebus.Receiver, error) {
+	return s.Subscription.NewReceiver(ctx, append(opts, servicebus.ReceiverWithPrefetchCount(s.prefetch))...)
+}
+
+// openEntity creates the client of the source entity on ns: the subscription set with WithTopicSubscription or else
+// the queue qName
+func (c *Convoy) openEntity(ns *servicebus.Namespace, qName string) (entity, error) {
+	if c.topicName == "" && c.subscriptionName == "" {
+		if qName == "" {
+			return nil, errors.New("queue name must not be empty")
+		}
+		q, err := ns.NewQueue(qName)
+		if err != nil {
+			return nil, err
+		}
+		c.queueName = qName
+		return &queueEntity{ns: ns, queue: q, name: qName}, nil
+	}
+
+	if qName != "" {
+		return nil, errors.New("a topic subscription cannot be combined with a queue name")
+	}
+	if c.topicName == "" || c.subscriptionName == "" {
+		return nil, errors.New("topic and subscription names must not be empty")
+	}
+	t, err := ns.NewTopic(c.topicName)
+	if err != nil {
+		return nil, err
+	}
+	s, err := t.NewSubscription(c.subscriptionName)
+	if err != nil {
+		return nil, err
+	}
+	// The entity path of the subscription, which identifies it like the name of a queue, e.g. in DeadLetterSource
+	c.queueName = c.topicName + "/Subscriptions/" + c.subscriptionName
+	return &subscriptionEntity{ns: ns, topic: t, subscription: s, topicName: c.topicName, name: c.subscriptionName}, nil
+}
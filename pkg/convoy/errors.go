@@ -0,0 +1,88 @@
+package convoy
+
+import (
+	"errors"
+	"net"
+
+	"github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-amqp"
+)
+
+// ErrEntityUnavailable is returned by Run when the queue does not exist or is disabled. Retrying does not help until
+// the entity is restored, so the convoy stops and leaves the decision to restart or alert to its supervisor.
+var ErrEntityUnavailable = errors.New("messaging entity not found or disabled")
+
+// AMQP error conditions reported by Service Bus
+const (
+	conditionTimeout         amqp.ErrorCondition = "com.microsoft:timeout"
+	conditionNotFound        amqp.ErrorCondition = "amqp:not-found"
+	conditionEntityDisabled  amqp.ErrorCondition = "com.microsoft:entity-disabled"
+	conditionMessageLockLost amqp.ErrorCondition = "com.microsoft:message-lock-lost"
+	conditionSessionLockLost amqp.ErrorCondition = "com.microsoft:session-lock-lost"
+	conditionServerBusy      amqp.ErrorCondition = "com.microsoft:server-busy"
+	conditionQuotaExceeded   amqp.ErrorCondition = "amqp:resource-limit-exceeded"
+)
+
+// amqpCondition returns the AMQP error condition carried by err
+func amqpCondition(err error) (amqp.ErrorCondition, bool) {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		return amqpErr.Condition, true
+	}
+
+	var detachErr *amqp.DetachError
+	if errors.As(err, &detachErr) && detachErr.RemoteError != nil {
+		return detachErr.RemoteError.Condition, true
+	}
+
+	return "", false
+}
+
+// isTimeout reports whether err signals that no session became available in time
+func isTimeout(err error) bool {
+	cond, ok := amqpCondition(err)
+	return ok && cond == conditionTimeout
+}
+
+// isServerBusy reports whether err signals that the broker throttles requests
+func isServerBusy(err error) bool {
+	cond, ok := amqpCondition(err)
+	return ok && cond == conditionServerBusy
+}
+
+// isQuotaExceeded reports whether err signals that the namespace's quota, e.g. of concurrent receivers, is exhausted
+func isQuotaExceeded(err error) bool {
+	cond, ok := amqpCondition(err)
+	return ok && cond == conditionQuotaExceeded
+}
+
+// isEntityUnavailable reports whether err signals that the queue was deleted or disabled
+func isEntityUnavailable(err error) bool {
+	if servicebus.IsErrNotFound(err) {
+		return true
+	}
+
+	cond, ok := amqpCondition(err)
+	return ok && (cond == conditionNotFound || cond == conditionEntityDisabled)
+}
+
+// isConnectionError reports whether err signals that the connection to the namespace could not be established or was
+// closed, e.g. while the broker is unreachable, or that the client panicked and its connection is not to be trusted
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, amqp.ErrConnClosed) || errors.Is(err, ErrSDKPanic)
+}
+
+// isLockLost reports whether err signals that the lock on the message or its session is gone, so the message can no
+// longer be settled by this receiver and will be redelivered by the broker
+func isLockLost(err error) bool {
+	if errors.Is(err, ErrLockExpired) {
+		return true
+	}
+	if cond, ok := amqpCondition(err); ok {
+		return cond == conditionMessageLockLost || cond == conditionSessionLockLost
+	}
+
+	var detachErr *amqp.DetachError
+	return errors.As(err, &detachErr) || errors.Is(err, amqp.ErrLinkClosed) || errors.Is(err, amqp.ErrSessionClosed)
+}
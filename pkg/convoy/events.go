@@ -0,0 +1,188 @@
+package convoy
+
+import (
+	"sync"
+	"time"
+)
+
+// EventKind is the lifecycle event an Event reports
+type EventKind string
+
+// Kinds of events emitted to an EventSink
+const (
+	EventSessionStarted      EventKind = "session_started"
+	EventSessionEnded        EventKind = "session_ended"
+	EventSessionExpired      EventKind = "session_expired"
+	EventMessageHandled      EventKind = "message_handled"
+	EventMessageFailed       EventKind = "message_failed"
+	EventMessageDeadLettered EventKind = "message_dead_lettered"
+	EventMessageDeferred     EventKind = "message_deferred"
+	EventReconnect           EventKind = "reconnect"
+
+	// EventProcessingStarted and EventProcessingFinished bracket every attempt to process a message, whatever its
+	// outcome. A start without a finish marks an attempt that never completed, e.g. because the process crashed.
+	EventProcessingStarted  EventKind = "processing_started"
+	EventProcessingFinished EventKind = "processing_finished"
+)
+
+// Event is a structured record of a lifecycle event of the convoy. Fields that do not apply to its kind are zero.
+type Event struct {
+	Kind           EventKind
+	Time           time.Time
+	SessionID      string
+	SequenceNumber int64
+	// CorrelationID is the correlation value of the message, see WithCorrelationProperty
+	CorrelationID string
+	// Outcome is the settlement of the message for message events and EventProcessingFinished
+	Outcome Outcome
+	// Latency is the time from receipt to settlement for message events and the time the session was held for
+	// EventSessionEnded
+	Latency time.Duration
+	Err     error
+}
+
+// EventSink receives the lifecycle events of the convoy, e.g. to route them to Kafka, a file or a test buffer.
+// Implementations must be safe for concurrent use.
+type EventSink interface {
+	Emit(Event)
+}
+
+// eventQueueSize bounds the events waiting for a slow EventSink before further ones are dropped
+const eventQueueSize = 1024
+
+// WithEventSink emits the lifecycle events of the convoy to sink: sessions started, ended and expired, processing of
+// messages started and finished, messages handled, failed, dead-lettered and deferred, and reconnects. Like metrics,
+// events are delivered from a goroutine of their own so that a slow or panicking sink never holds up processing;
+// events are dropped while the sink falls behind.
+func WithEventSink(sink EventSink) Option {
+	return func(c *Convoy) {
+		c.events = newEventQueue(sink, c.logf)
+	}
+}
+
+// EventBuffer is an EventSink that keeps all events in memory, e.g. for assertions in tests
+type EventBuffer struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Emit records e
+func (b *EventBuffer) Emit(e Event) {
+	b.mu.Lock()
+	b.events = append(b.events, e)
+	b.mu.Unlock()
+}
+
+// Events returns the events recorded so far in the order they were emitted
+func (b *EventBuffer) Events() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Event(nil), b.events...)
+}
+
+// eventQueue delivers events to the sink from a goroutine of its own
+type eventQueue struct {
+	sink     EventSink
+	events   chan Event
+	quit     chan struct{}
+	quitOnce sync.Once
+	logf     func(format string, v ...interface{})
+
+	dropped  sync.Once
+	panicked sync.Once
+}
+
+func newEventQueue(sink EventSink, logf func(format string, v ...interface{})) *eventQueue {
+	q := &eventQueue{
+		sink:   sink,
+		events: make(chan Event, eventQueueSize),
+		quit:   make(chan struct{}),
+		logf:   logf,
+	}
+	go q.forward()
+	return q
+}
+
+// emit hands e to the forwarding goroutine without ever blocking
+func (q *eventQueue) emit(e Event) {
+	select {
+	case <-q.quit:
+		return
+	default:
+	}
+
+	select {
+	case q.events <- e:
+	default:
+		q.dropped.Do(func() {
+			q.logf("❗ Event sink is not keeping up. Dropping events until it does.")
+		})
+	}
+}
+
+// forward delivers the queued events until the convoy is closed
+func (q *eventQueue) forward() {
+	for {
+		select {
+		case e := <-q.events:
+			q.deliver(e)
+		case <-q.quit:
+			return
+		}
+	}
+}
+
+// deliver emits a single event, recovering a panic of the sink
+func (q *eventQueue) deliver(e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			q.panicked.Do(func() {
+				q.logf("❗ Event sink panicked, ignoring further panics: %v", r)
+			})
+		}
+	}()
+	q.sink.Emit(e)
+}
+
+// stop ends the forwarding goroutine. Later events are dropped.
+func (q *eventQueue) stop() {
+	q.quitOnce.Do(func() {
+		close(q.quit)
+	})
+}
+
+// emit stamps e with the current time, keeps it for DebugHandler and emits it if an event sink is set
+func (c *Convoy) emit(e Event) {
+	e.Time = time.Now()
+	c.recent.add(e)
+	if c.events == nil {
+		return
+	}
+	c.events.emit(e)
+}
+
+// messageEvent returns the kind of event reporting a message settled with outcome
+func messageEvent(outcome Outcome) (EventKind, bool) {
+	switch outcome {
+	case OutcomeCompleted:
+		return EventMessageHandled, true
+	case OutcomeAbandoned:
+		return EventMessageFailed, true
+	case OutcomeDeadLettered:
+		return EventMessageDeadLettered, true
+	case OutcomeDeferred:
+		return EventMessageDeferred, true
+	default:
+		return "", false
+	}
+}
+
+// firstErr returns the first non-nil error
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
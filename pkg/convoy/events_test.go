@@ -0,0 +1,60 @@
+package convoy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// awaitEvents waits until buf holds n events and returns them
+func awaitEvents(t *testing.T, buf *EventBuffer, n int) []Event {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(buf.Events()) < n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return buf.Events()
+}
+
+func TestEventSinkCapturesLifecycle(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	var buf EventBuffer
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		if msg.ID == "a-2" {
+			return &ErrDeadLetter{Reason: "Invalid", Description: "invalid order"}
+		}
+		return nil
+	}, WithEventSink(&buf))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	want := []EventKind{
+		EventSessionStarted,
+		EventProcessingStarted, EventMessageHandled, EventProcessingFinished,
+		EventProcessingStarted, EventMessageDeadLettered, EventProcessingFinished,
+		EventSessionEnded,
+	}
+	events := awaitEvents(t, &buf, len(want))
+	var kinds []EventKind
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+		if e.SessionID != "a" {
+			t.Errorf("%s event of session %q, want a", e.Kind, e.SessionID)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("%s event without time", e.Kind)
+		}
+	}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("events %v, want %v", kinds, want)
+	}
+	if e := events[5]; e.SequenceNumber != 2 || e.Outcome != OutcomeDeadLettered {
+		t.Errorf("dead-letter event %+v, want message 2 dead-lettered", e)
+	}
+}
@@ -0,0 +1,59 @@
+package convoy_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+
+	"tcblabs.net/sequentialconvoy/pkg/convoy"
+)
+
+// processStep handles one step of a session: nil completes the message, ErrDeadLetter moves it to the dead-letter
+// queue and a context error, e.g. from the handler timeout, abandons it for redelivery
+func processStep(ctx context.Context, msg *servicebus.Message) error {
+	fmt.Printf("[%s] Session: %s Data: %s\n", convoy.CorrelationID(ctx), *msg.SessionID, msg.Data)
+
+	if len(msg.Data) == 0 {
+		return &convoy.ErrDeadLetter{Reason: "EmptyBody", Description: "step message has no body"}
+	}
+
+	select {
+	case <-time.After(time.Second):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// The processor hands the messages of every session to the handler in order, one at a time, until it is stopped. Stop
+// drains the sessions being handled before closing the clients.
+func ExampleNewProcessor() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p, err := convoy.NewProcessor(os.Getenv("SERVICEBUS_CONNECTION_STRING"), "steps", convoy.HandlerFunc(processStep),
+		convoy.WithIdleTimeout(30*time.Second),
+		convoy.WithHandlerTimeout(10*time.Second),
+		convoy.WithConcurrentSessions(4),
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := p.Start(ctx); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-p.Done():
+	}
+	if err := p.Stop(); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,113 @@
+package convoy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const expiryWindow = time.Minute
+
+// ErrConvoyStalled is returned by Run when the watchdog closed the number of sessions set with
+// WithMaxConsecutiveExpiries in a row without any message being completed in between
+var ErrConvoyStalled = errors.New("convoy stalled")
+
+// WithExpiryGrace delays the watchdog's reaction to a stale session: a session counts as stale only once buffer has
+// passed beyond the idle timeout, and the watchdog acts only after confirmations consecutive checks found it stale.
+// This avoids closing a session whose producer publishes at a cadence close to the idle timeout because of a single
+// slightly late message. The price is detection latency: an idle session or a hung handler is detected up to
+// buffer plus confirmations watchdog intervals after the idle timeout elapsed.
+func WithExpiryGrace(buffer time.Duration, confirmations int) Option {
+	return func(c *Convoy) {
+		c.expiryGrace = buffer
+		c.expiryConfirmations = confirmations
+	}
+}
+
+// WithExpiryAlert calls fn with the number of watchdog-triggered session expiries in the last minute whenever an
+// expiry brings that number above maxPerMinute. A burst of expiries usually means handlers are slowed down by a
+// downstream dependency. fn is called from the watchdog and should return quickly.
+func WithExpiryAlert(maxPerMinute int, fn func(count int)) Option {
+	return func(c *Convoy) {
+		c.expiryAlert = &expiryRate{
+			max:   maxPerMinute,
+			alert: fn,
+		}
+	}
+}
+
+// WithMaxConsecutiveExpiries stops the convoy with ErrConvoyStalled once the watchdog has closed n sessions in a
+// row without a message being completed in between, e.g. because a downstream dependency is so slow that no session
+// makes progress. Rather than churning through sessions forever the convoy leaves the decision to its supervisor. The
+// count is reset by every completed message.
+func WithMaxConsecutiveExpiries(n int) Option {
+	return func(c *Convoy) {
+		c.maxExpiries = n
+	}
+}
+
+// expiryRate counts expiries over a sliding window
+type expiryRate struct {
+	sync.Mutex
+	max   int
+	alert func(count int)
+	times []time.Time
+}
+
+// record adds an expiry at now and returns the number of expiries within the window ending at now
+func (r *expiryRate) record(now time.Time) int {
+	r.Lock()
+	defer r.Unlock()
+
+	cutoff := now.Add(-expiryWindow)
+	kept := r.times[:0]
+	for _, t := range r.times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	r.times = append(kept, now)
+
+	return len(r.times)
+}
+
+// sessionExpired reports an expiry to the metrics and, when the rate is exceeded, to the alert callback
+func (c *Convoy) sessionExpired() {
+	c.metrics.IncCounter(metricSessionExpiries)
+	if n := atomic.AddInt64(&c.expiries, 1); c.maxExpiries > 0 && n == int64(c.maxExpiries) {
+		c.logf("🚨 %d consecutive sessions expired without a completed message. Stopping convoy.", n)
+	}
+	if c.expiryAlert == nil {
+		return
+	}
+
+	if count := c.expiryAlert.record(time.Now()); count > c.expiryAlert.max {
+		c.logf("🚨 %d sessions expired within the last minute.", count)
+		c.alertExpiries(count)
+	}
+}
+
+// alertExpiries calls the alert callback on the watchdog, recovering a panic which is logged by recoverPanic
+func (c *Convoy) alertExpiries(count int) (err error) {
+	defer c.recoverPanic(ErrHandlerPanic, "alerting expired sessions", &err)
+	c.expiryAlert.alert(count)
+	return nil
+}
+
+// completed resets the consecutive expiries after a message was completed
+func (c *Convoy) completed() {
+	atomic.StoreInt64(&c.expiries, 0)
+}
+
+// stalled returns ErrConvoyStalled if the maximum of consecutive expiries was reached
+func (c *Convoy) stalled() error {
+	if c.maxExpiries <= 0 {
+		return nil
+	}
+	if n := atomic.LoadInt64(&c.expiries); n >= int64(c.maxExpiries) {
+		return fmt.Errorf("%w: %d consecutive session expiries without a completed message", ErrConvoyStalled, n)
+	}
+	return nil
+}
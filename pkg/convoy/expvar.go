@@ -0,0 +1,62 @@
+package convoy
+
+import (
+	"expvar"
+)
+
+// WithExpvar publishes the convoy's metrics through the expvar package as a map named prefix, served as JSON by the
+// default /debug/vars handler, in addition to the Metrics set with WithMetrics. The map holds the counters and gauges
+// under their metric names, observations as a _count and _sum pair, and the last error that stopped a run as
+// last_error. Convoys using the same prefix share the map.
+func WithExpvar(prefix string) Option {
+	return func(c *Convoy) {
+		c.expvarPrefix = prefix
+	}
+}
+
+// expvarMetrics reports metrics into an expvar map
+type expvarMetrics struct {
+	vars *expvar.Map
+	next Metrics
+}
+
+// newExpvarMetrics publishes the map named prefix, or reuses it if it is already published
+func newExpvarMetrics(prefix string, next Metrics) *expvarMetrics {
+	vars, ok := expvar.Get(prefix).(*expvar.Map)
+	if !ok {
+		vars = expvar.NewMap(prefix)
+	}
+	return &expvarMetrics{vars: vars, next: next}
+}
+
+func (m *expvarMetrics) IncCounter(name string) {
+	m.vars.Add(name, 1)
+	m.next.IncCounter(name)
+}
+
+func (m *expvarMetrics) SetGauge(name string, value float64) {
+	f := new(expvar.Float)
+	f.Set(value)
+	m.vars.Set(name, f)
+	m.next.SetGauge(name, value)
+}
+
+func (m *expvarMetrics) Observe(name string, value float64) {
+	m.vars.Add(name+"_count", 1)
+	m.vars.AddFloat(name+"_sum", value)
+	m.next.Observe(name, value)
+}
+
+// recordError publishes err as the last error of the convoy
+func (m *expvarMetrics) recordError(err error) {
+	s := new(expvar.String)
+	s.Set(err.Error())
+	m.vars.Set("last_error", s)
+}
+
+// recordRunError publishes the error that stopped a run if expvar is enabled
+func (c *Convoy) recordRunError(err error) {
+	if c.expvar != nil && err != nil {
+		c.expvar.recordError(err)
+	}
+}
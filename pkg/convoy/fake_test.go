@@ -0,0 +1,378 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-amqp"
+)
+
+// fakeBroker is an in-memory session enabled queue. It hands out each session to one receiver at a time, delivers
+// the messages of a session in order and applies settlements the way Service Bus does: abandoned and unsettled
+// messages return to the front of their session, completed, deferred and dead-lettered ones leave it.
+type fakeBroker struct {
+	mu       sync.Mutex
+	order    []string
+	pending  map[string][]*servicebus.Message
+	inFlight map[string][]*servicebus.Message
+	locked   map[string]bool
+	seq      int64
+	settled  []fakeSettlement
+	sent     []*servicebus.Message
+
+	// Properties returned by describe
+	description entityDescription
+
+	// sendErr, if set, fails the sends it returns an error for
+	sendErr func(msg *servicebus.Message) error
+	// settleLatency delays every settlement, standing in for the round trip to the namespace
+	settleLatency time.Duration
+	// settleErr, if set, fails the settlements it returns an error for
+	settleErr func(msg *servicebus.Message) error
+	// receiveErr, if set, is returned by every receive instead of accepting a session
+	receiveErr func() error
+	// renewErrs are handed out one per accepted session as the error of its lock renewals
+	renewErrs []error
+	// maxInFlight is the most messages of a session delivered but not yet settled at once
+	maxInFlight int
+	// states holds the state of each session while it is not locked
+	states map[string][]byte
+}
+
+// fakeSettlement records the settlement of a message by the convoy
+type fakeSettlement struct {
+	sessionID string
+	messageID string
+	outcome   Outcome
+	reason    string
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		pending:  map[string][]*servicebus.Message{},
+		inFlight: map[string][]*servicebus.Message{},
+		locked:   map[string]bool{},
+		states:   map[string][]byte{},
+	}
+}
+
+// add enqueues a message with body for each of bodies to session sessionID. Message IDs are the session ID followed by
+// the position of the message in the session. It returns the messages for the test to adjust before they are received.
+func (b *fakeBroker) add(sessionID string, bodies ...string) []*servicebus.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.pending[sessionID]; !ok {
+		b.order = append(b.order, sessionID)
+	}
+	var added []*servicebus.Message
+	for _, body := range bodies {
+		b.seq++
+		seq, id := b.seq, sessionID
+		msg := servicebus.NewMessageFromString(body)
+		msg.ID = fmt.Sprintf("%s-%d", sessionID, len(b.pending[sessionID])+len(b.inFlight[sessionID])+1)
+		msg.SessionID = &id
+		msg.DeliveryCount = 1
+		msg.SystemProperties = &servicebus.SystemProperties{SequenceNumber: &seq}
+		b.pending[sessionID] = append(b.pending[sessionID], msg)
+		added = append(added, msg)
+	}
+	return added
+}
+
+// lock locks session sessionID, or the unlocked session with messages that waited longest if it is nil
+func (b *fakeBroker) lock(sessionID *string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, id := range b.order {
+		if (sessionID == nil || *sessionID == id) && !b.locked[id] && len(b.pending[id]) > 0 {
+			b.locked[id] = true
+			// Sessions are handed out round-robin, so a released session goes behind those that are waiting
+			b.order = append(append(b.order[:i:i], b.order[i+1:]...), id)
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// unlock returns the unsettled messages of session sessionID to the front of the session and unlocks it
+func (b *fakeBroker) unlock(sessionID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, msg := range b.inFlight[sessionID] {
+		b.requeue(msg)
+	}
+	delete(b.inFlight, sessionID)
+	b.locked[sessionID] = false
+}
+
+// next delivers the next message of session sessionID
+func (b *fakeBroker) next(sessionID string) (*servicebus.Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msgs := b.pending[sessionID]
+	if len(msgs) == 0 {
+		return nil, false
+	}
+	b.pending[sessionID] = msgs[1:]
+	b.inFlight[sessionID] = append(b.inFlight[sessionID], msgs[0])
+	if n := len(b.inFlight[sessionID]); n > b.maxInFlight {
+		b.maxInFlight = n
+	}
+	return msgs[0], true
+}
+
+// requeue returns msg to its session in sequence order as a new delivery. The caller holds the lock.
+func (b *fakeBroker) requeue(msg *servicebus.Message) {
+	msg.DeliveryCount++
+	id := *msg.SessionID
+	msgs := append(b.pending[id], msg)
+	sort.Slice(msgs, func(i, j int) bool {
+		return *msgs[i].SystemProperties.SequenceNumber < *msgs[j].SystemProperties.SequenceNumber
+	})
+	b.pending[id] = msgs
+}
+
+// settlements returns the settlements applied so far
+func (b *fakeBroker) settlements() []fakeSettlement {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]fakeSettlement(nil), b.settled...)
+}
+
+// remaining returns the number of messages not settled yet
+func (b *fakeBroker) remaining() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := 0
+	for id := range b.pending {
+		n += len(b.pending[id]) + len(b.inFlight[id])
+	}
+	return n
+}
+
+func (b *fakeBroker) newSession(sessionID *string, prefetch uint32) sessionReceiver {
+	return &fakeReceiver{broker: b, sessionID: sessionID, prefetch: prefetch}
+}
+
+func (b *fakeBroker) send(_ context.Context, msg *servicebus.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sendErr != nil {
+		if err := b.sendErr(msg); err != nil {
+			return err
+		}
+	}
+	b.sent = append(b.sent, msg)
+	return nil
+}
+
+func (b *fakeBroker) describe(context.Context) (entityDescription, error) {
+	return b.description, nil
+}
+
+func (b *fakeBroker) settle(_ context.Context, msg *servicebus.Message, st settlement) error {
+	if st.outcome == OutcomeReleased {
+		return nil
+	}
+	if b.settleLatency > 0 {
+		time.Sleep(b.settleLatency)
+	}
+	if b.settleErr != nil {
+		if err := b.settleErr(msg); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The message is looked up by identity, so that one whose session ID was cleared is settled as well
+	id := sessionIDOf(msg)
+	for sid, delivered := range b.inFlight {
+		for i, m := range delivered {
+			if m == msg {
+				b.inFlight[sid] = append(delivered[:i:i], delivered[i+1:]...)
+				break
+			}
+		}
+	}
+	if st.outcome == OutcomeAbandoned {
+		b.requeue(msg)
+	}
+
+	s := fakeSettlement{sessionID: id, messageID: msg.ID, outcome: st.outcome}
+	if st.deadLetter != nil {
+		s.reason = st.deadLetter.Reason
+	}
+	b.settled = append(b.settled, s)
+	return nil
+}
+
+func (b *fakeBroker) close(context.Context) error {
+	return nil
+}
+
+// fakeReceiver accepts a session of a fakeBroker and hands its messages to the session handler of the convoy until
+// the session runs out of messages or is released, fetching up to prefetch messages ahead. It times out right away if
+// no session is available.
+type fakeReceiver struct {
+	broker    *fakeBroker
+	sessionID *string
+	prefetch  uint32
+}
+
+func (r *fakeReceiver) ReceiveOne(ctx context.Context, handler servicebus.SessionHandler) error {
+	sh := handler.(*StepSessionHandler)
+	if r.broker.receiveErr != nil {
+		if err := r.broker.receiveErr(); err != nil {
+			return err
+		}
+	}
+	id, ok := r.broker.lock(r.sessionID)
+	if !ok {
+		return &amqp.Error{Condition: conditionTimeout, Description: "no session available"}
+	}
+	defer r.broker.unlock(id)
+
+	ms := &fakeSession{id: id, lockedUntil: time.Now().Add(time.Minute), closed: make(chan struct{})}
+	r.broker.mu.Lock()
+	ms.state = r.broker.states[id]
+	if len(r.broker.renewErrs) > 0 {
+		ms.renewErr, r.broker.renewErrs = r.broker.renewErrs[0], r.broker.renewErrs[1:]
+	}
+	r.broker.mu.Unlock()
+	defer func() {
+		state, _ := ms.State(ctx)
+		r.broker.mu.Lock()
+		r.broker.states[id] = state
+		r.broker.mu.Unlock()
+	}()
+	if err := sh.start(ms); err != nil {
+		return err
+	}
+	defer sh.End()
+
+	var fetched []*servicebus.Message
+	for !ms.isClosed() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for len(fetched) == 0 || uint32(len(fetched)) < r.prefetch {
+			msg, ok := r.broker.next(id)
+			if !ok {
+				break
+			}
+			fetched = append(fetched, msg)
+		}
+		if len(fetched) == 0 {
+			return nil
+		}
+		msg := fetched[0]
+		fetched = fetched[1:]
+		if err := sh.Handle(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *fakeReceiver) ReceiveDeferred(context.Context, servicebus.Handler, servicebus.ReceiveMode, ...int64) error {
+	return nil
+}
+
+func (r *fakeReceiver) Close(context.Context) error {
+	return nil
+}
+
+// fakeSession is the lock on a session of a fakeBroker
+type fakeSession struct {
+	id string
+
+	mu          sync.Mutex
+	lockedUntil time.Time
+	renewals    int
+	renewErr    error
+	state       []byte
+	closeOnce   sync.Once
+	closed      chan struct{}
+}
+
+func (s *fakeSession) SessionID() *string {
+	return &s.id
+}
+
+func (s *fakeSession) LockedUntil() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lockedUntil
+}
+
+func (s *fakeSession) RenewLock(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewals++
+	if s.renewErr != nil {
+		return s.renewErr
+	}
+	s.lockedUntil = time.Now().Add(time.Minute)
+	return nil
+}
+
+func (s *fakeSession) State(context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, nil
+}
+
+func (s *fakeSession) SetState(_ context.Context, state []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	return nil
+}
+
+func (s *fakeSession) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+func (s *fakeSession) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// newTestConvoy creates a convoy receiving from broker. Its log output is discarded and its lock duration set.
+func newTestConvoy(tb testing.TB, broker *fakeBroker, handler HandlerFunc, opts ...Option) *Convoy {
+	tb.Helper()
+	defaults := []Option{WithLogger(log.New(io.Discard, "", 0)), WithLockDuration(time.Minute)}
+	c, err := newConvoy(handler, append(defaults, opts...))
+	if err != nil {
+		tb.Fatalf("newConvoy: %v", err)
+	}
+	c.source = broker
+	c.queueName = "fake"
+	tb.Cleanup(func() {
+		c.Close(context.Background())
+	})
+	return c
+}
+
+// nopHandler completes every message
+func nopHandler(context.Context, *servicebus.Message) error {
+	return nil
+}
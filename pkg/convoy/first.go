@@ -0,0 +1,47 @@
+package convoy
+
+import (
+	"context"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithOnFirstMessage calls fn with the first message of every session the convoy accepts, before the handler, e.g. to
+// load the state of the session or to validate a header message. It runs once per acceptance: a session that is
+// released and accepted again, by this or another receiver, runs it again with the message it resumes at. If fn
+// succeeds the handler is called as usual. Otherwise the handler is skipped and the message is settled by the error of
+// fn like by that of a handler, except that an error other than the settlement sentinels dead-letters the message.
+// Return ErrRetryLater to abort the session instead, leaving the message to be tried again once it is accepted anew.
+func WithOnFirstMessage(fn func(ctx context.Context, msg *servicebus.Message) error) Option {
+	return func(c *Convoy) {
+		c.onFirstMessage = fn
+	}
+}
+
+// firstMessage runs the hook set with WithOnFirstMessage if msg is the first message handled in this session. It
+// reports the settlement of msg and whether the hook failed, in which case the handler must not be called.
+func (sh *StepSessionHandler) firstMessage(ctx context.Context, msg *servicebus.Message) (settlement, bool) {
+	if sh.convoy.onFirstMessage == nil {
+		return settlement{}, false
+	}
+
+	sh.Lock()
+	first := !sh.firstSeen
+	sh.firstSeen = true
+	sh.Unlock()
+	if !first {
+		return settlement{}, false
+	}
+
+	err := sh.convoy.onFirstMessage(ctx, msg)
+	if err == nil {
+		return settlement{}, false
+	}
+
+	sh.convoy.msgLogf(ctx, "❗ First message hook of session failed: %v", err)
+	st := settlementFor(err)
+	if st.err != nil {
+		st = settlementFor(&ErrDeadLetter{Reason: "FirstMessageFailed", Description: err.Error()})
+	}
+	return st, true
+}
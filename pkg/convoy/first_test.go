@@ -0,0 +1,61 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestOnFirstMessageRunsOncePerSession(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2", "3")
+	broker.add("b", "1", "2")
+
+	var first, handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	}, WithOnFirstMessage(func(_ context.Context, msg *servicebus.Message) error {
+		first = append(first, msg.ID)
+		return nil
+	}), WithMaxMessagesPerSessionVisit(2))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// Session a is released after two messages and runs the hook again with the message it resumes at
+	sort.Strings(first)
+	if want := []string{"a-1", "a-3", "b-1"}; !reflect.DeepEqual(first, want) {
+		t.Errorf("first message hook ran for %v, want %v", first, want)
+	}
+	if len(handled) != 5 {
+		t.Errorf("handled %v, want all 5 messages", handled)
+	}
+}
+
+func TestOnFirstMessageFailureSkipsHandler(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	}, WithOnFirstMessage(func(context.Context, *servicebus.Message) error {
+		return errors.New("invalid header")
+	}))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if want := []string{"a-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if got, want := outcomesOf(broker), []Outcome{OutcomeDeadLettered, OutcomeCompleted}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+}
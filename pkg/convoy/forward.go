@@ -0,0 +1,100 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// ForwardOption configures how dead-lettered messages are forwarded
+type ForwardOption func(*deadLetterForward)
+
+// ForwardKeepDeadLetter also dead-letters the original message in the queue's own dead-letter queue after forwarding
+// the copy, instead of completing it
+func ForwardKeepDeadLetter() ForwardOption {
+	return func(f *deadLetterForward) {
+		f.keepDeadLetter = true
+	}
+}
+
+// ForwardSessionID keeps the session ID of the original message on the copy. It is required when the target is
+// session enabled.
+func ForwardSessionID() ForwardOption {
+	return func(f *deadLetterForward) {
+		f.keepSessionID = true
+	}
+}
+
+// WithDeadLetterForward sends a copy of every message the convoy would dead-letter to target, a queue or topic in the
+// same namespace, and then completes the original, so poison messages of several convoys can be handled in one place.
+// The copy carries the body, content type, correlation ID and application properties of the original, and the failure
+// in the DeadLetterReason, DeadLetterErrorDescription and DeadLetterSource properties. The copy keeps the message ID
+// of the original, which makes forwarding idempotent on targets with duplicate detection. If the copy cannot be sent,
+// the original is dead-lettered in the queue's own dead-letter queue so it is never lost.
+func WithDeadLetterForward(target string, opts ...ForwardOption) Option {
+	return func(c *Convoy) {
+		c.forward = &deadLetterForward{target: target}
+		for _, opt := range opts {
+			opt(c.forward)
+		}
+	}
+}
+
+// deadLetterForward is the target of forwarded dead-letters
+type deadLetterForward struct {
+	target         string
+	keepDeadLetter bool
+	keepSessionID  bool
+	sender         *servicebus.Queue
+}
+
+// validate checks the forward settings
+func (f *deadLetterForward) validate() error {
+	if f.target == "" {
+		return errors.New("dead-letter forward target must not be empty")
+	}
+	return nil
+}
+
+// forwardDeadLetter sends a copy of the message dead-lettered by st to the forward target and returns the settlement
+// that replaces the dead-lettering of the original
+func (sh *StepSessionHandler) forwardDeadLetter(ctx context.Context, msg *servicebus.Message, st settlement) settlement {
+	f := sh.convoy.forward
+
+	cp := copyMessage(msg)
+	cp.ID = msg.ID
+	cp.UserProperties["DeadLetterReason"] = st.deadLetter.Reason
+	cp.UserProperties["DeadLetterErrorDescription"] = st.deadLetter.Description
+	cp.UserProperties["DeadLetterSource"] = sh.convoy.queueName
+	for k, v := range sh.convoy.deadLetterProperties(ctx, msg) {
+		cp.UserProperties[k] = v
+	}
+	if f.keepSessionID {
+		cp.SessionID = msg.SessionID
+	}
+
+	if err := f.sender.Send(ctx, cp); err != nil {
+		sh.convoy.msgLogf(ctx, "❗ Failed to forward dead-lettered message to %s, dead-lettering it in place: %v", f.target, err)
+		return st
+	}
+
+	sh.convoy.msgLogf(ctx, "📤 Forwarded dead-lettered message to %s.", f.target)
+	if f.keepDeadLetter {
+		return st
+	}
+	st.outcome = OutcomeCompleted
+	return st
+}
+
+// copyMessage returns a new message with the body, content type, correlation ID and application properties of msg
+func copyMessage(msg *servicebus.Message) *servicebus.Message {
+	cp := servicebus.NewMessage(msg.Data)
+	cp.ContentType = msg.ContentType
+	cp.CorrelationID = msg.CorrelationID
+	cp.UserProperties = make(map[string]interface{}, len(msg.UserProperties)+3)
+	for k, v := range msg.UserProperties {
+		cp.UserProperties[k] = v
+	}
+	return cp
+}
@@ -0,0 +1,70 @@
+package convoy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithGlobalOrdering orders completions across concurrent sessions by sequence number, for downstream systems that
+// consume completions and require a single global order rather than one per session, e.g. when replicating the queue
+// into a log. Handlers of different sessions still run concurrently, but a message is only completed once no message
+// with a lower sequence number is in flight in any session. The cost is throughput: every completion waits for the
+// slowest handler running on an earlier message, so a single slow session holds back all others. The order covers the
+// messages in flight at the same time; a message accepted later cannot be ordered before completions already made. Most
+// convoys need neither: per-session order is what the sequential convoy pattern guarantees.
+func WithGlobalOrdering() Option {
+	return func(c *Convoy) {
+		c.globalOrder = &completionOrder{inFlight: make(map[int64]struct{}), changed: make(chan struct{})}
+	}
+}
+
+// completionOrder tracks the sequence numbers of the messages in flight across sessions
+type completionOrder struct {
+	mu       sync.Mutex
+	inFlight map[int64]struct{}
+	changed  chan struct{}
+}
+
+// register marks msg as in flight
+func (o *completionOrder) register(msg *servicebus.Message) {
+	o.mu.Lock()
+	o.inFlight[sequenceOf(msg)] = struct{}{}
+	o.mu.Unlock()
+}
+
+// done marks msg as no longer in flight and wakes the completions waiting for it
+func (o *completionOrder) done(msg *servicebus.Message) {
+	o.mu.Lock()
+	delete(o.inFlight, sequenceOf(msg))
+	close(o.changed)
+	o.changed = make(chan struct{})
+	o.mu.Unlock()
+}
+
+// await returns once msg has the lowest sequence number in flight, or when ctx is done
+func (o *completionOrder) await(ctx context.Context, msg *servicebus.Message) error {
+	seq := sequenceOf(msg)
+	for {
+		o.mu.Lock()
+		lowest := true
+		for s := range o.inFlight {
+			if s < seq {
+				lowest = false
+				break
+			}
+		}
+		changed := o.changed
+		o.mu.Unlock()
+		if lowest {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
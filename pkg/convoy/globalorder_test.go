@@ -0,0 +1,81 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// sequenced returns a message with sequence number seq
+func sequenced(seq int64) *servicebus.Message {
+	return &servicebus.Message{SystemProperties: &servicebus.SystemProperties{SequenceNumber: &seq}}
+}
+
+// awaitAsync runs order.await for msg and returns the channel its result is sent to
+func awaitAsync(ctx context.Context, order *completionOrder, msg *servicebus.Message) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- order.await(ctx, msg)
+	}()
+	return errc
+}
+
+func TestCompletionOrderWaitsForLowerSequenceNumbers(t *testing.T) {
+	c := &Convoy{}
+	WithGlobalOrdering()(c)
+	order := c.globalOrder
+	first, second, third := sequenced(1), sequenced(2), sequenced(3)
+	for _, msg := range []*servicebus.Message{third, first, second} {
+		order.register(msg)
+	}
+
+	if err := order.await(context.Background(), first); err != nil {
+		t.Fatalf("await of the lowest message: %v", err)
+	}
+	errc := awaitAsync(context.Background(), order, third)
+
+	// Completing a higher message does not release the third, only the last lower one does
+	order.done(first)
+	select {
+	case err := <-errc:
+		t.Fatalf("await returned %v while a lower message is in flight", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	order.done(second)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("await = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("await did not return after the lower messages were done")
+	}
+}
+
+func TestCompletionOrderIgnoresHigherSequenceNumbers(t *testing.T) {
+	c := &Convoy{}
+	WithGlobalOrdering()(c)
+	c.globalOrder.register(sequenced(2))
+	c.globalOrder.register(sequenced(7))
+
+	if err := c.globalOrder.await(context.Background(), sequenced(2)); err != nil {
+		t.Errorf("await = %v, want nil", err)
+	}
+}
+
+func TestCompletionOrderAwaitCancelled(t *testing.T) {
+	c := &Convoy{}
+	WithGlobalOrdering()(c)
+	c.globalOrder.register(sequenced(1))
+	c.globalOrder.register(sequenced(2))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := awaitAsync(ctx, c.globalOrder, sequenced(2))
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("await = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,89 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// Source is a queue processed by a Group along with the options of its convoy
+type Source struct {
+	queue string
+	opts  []Option
+}
+
+// WithSource configures a queue of a Group. opts apply to the convoy of this queue only, so that sources with
+// different workloads can be tuned independently, e.g. WithConcurrentSessions for the number of sessions processed at
+// once and WithReceiveStrategy with ReceiveWindow for the number of messages prefetched.
+func WithSource(queue string, opts ...Option) Source {
+	return Source{queue: queue, opts: opts}
+}
+
+// Group processes several queues of one namespace with the same handler. Each source runs on a convoy of its own,
+// which enforces its limits independently of the other sources.
+type Group struct {
+	convoys []*Convoy
+}
+
+// NewGroup creates a convoy for every source on the shared namespace ns. The options of each source are validated
+// separately and an invalid source is reported by its queue name, after closing the convoys already created.
+func NewGroup(ns *servicebus.Namespace, handler HandlerFunc, sources ...Source) (*Group, error) {
+	g := &Group{}
+	seen := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		c, err := NewWithNamespace(ns, src.queue, handler, src.opts...)
+		if err != nil {
+			g.Close(context.Background())
+			return nil, fmt.Errorf("source %s: %w", src.queue, err)
+		}
+		g.convoys = append(g.convoys, c)
+		// Sources are told apart by their entity path, which also covers subscriptions set with WithTopicSubscription
+		if seen[c.queueName] {
+			g.Close(context.Background())
+			return nil, fmt.Errorf("source %s configured twice", c.queueName)
+		}
+		seen[c.queueName] = true
+	}
+
+	return g, nil
+}
+
+// Run runs the convoys of all sources until ctx is cancelled or one of them fails, which stops the others as well. It
+// returns the first error, prefixed with the queue name of its source.
+func (g *Group) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(g.convoys))
+	for _, c := range g.convoys {
+		go func(c *Convoy) {
+			if err := c.Run(ctx); err != nil {
+				errc <- fmt.Errorf("source %s: %w", c.queueName, err)
+				return
+			}
+			errc <- nil
+		}(c)
+	}
+
+	var first error
+	for range g.convoys {
+		if err := <-errc; err != nil && first == nil {
+			first = err
+			cancel()
+		}
+	}
+
+	return first
+}
+
+// Close closes the convoys of all sources and returns the first error
+func (g *Group) Close(ctx context.Context) error {
+	var first error
+	for _, c := range g.convoys {
+		if err := c.Close(ctx); err != nil && first == nil {
+			first = fmt.Errorf("source %s: %w", c.queueName, err)
+		}
+	}
+	return first
+}
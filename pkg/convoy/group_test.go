@@ -0,0 +1,45 @@
+package convoy
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestNewGroupRejectsBadSources(t *testing.T) {
+	ns, err := servicebus.NewNamespace(servicebus.NamespaceWithConnectionString(
+		"Endpoint=sb://example.servicebus.windows.net/;SharedAccessKeyName=listen;SharedAccessKey=secret"))
+	if err != nil {
+		t.Fatalf("NewNamespace: %v", err)
+	}
+
+	quiet := WithLogger(log.New(io.Discard, "", 0))
+	tests := []struct {
+		name    string
+		sources []Source
+		wantErr string
+	}{
+		{
+			name:    "duplicate",
+			sources: []Source{WithSource("orders", quiet), WithSource("payments", quiet), WithSource("orders", quiet)},
+			wantErr: "source orders configured twice",
+		},
+		{
+			name:    "invalid options",
+			sources: []Source{WithSource("orders", quiet), WithSource("payments", quiet, WithEmptyAccepts(-1))},
+			wantErr: "source payments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := NewGroup(ns, nopHandler, tt.sources...)
+			if g != nil || err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewGroup = %v, %v, want an error containing %q", g, err, tt.wantErr)
+			}
+		})
+	}
+}
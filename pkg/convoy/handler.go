@@ -0,0 +1,683 @@
+package convoy
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// HandlerFunc processes a single session message. It must not settle the message itself; the returned error selects
+// the settlement, see ErrAbandon, ErrDefer, ErrRetryLater and ErrDeadLetter.
+type HandlerFunc func(ctx context.Context, msg *servicebus.Message) error
+
+type heartbeatKey struct{}
+
+type StepSessionHandler struct {
+	sync.RWMutex
+	lastProcessedAt time.Time
+	lastActive      time.Duration
+	messageSession  lockedSession
+	convoy          *Convoy
+	stats           *runStats
+
+	// A targeted session is processed with its own handler, regardless of sharding and circuit breakers
+	handler  HandlerFunc
+	targeted bool
+
+	// Per-session bookkeeping, reset by Start and reported once by End
+	started   bool
+	ended     bool
+	sessionID string
+	processed int
+	startedAt time.Time
+	released  bool
+	pending   []*pendingSettlement
+	stopRenew chan struct{}
+	received  bool
+	empty     *time.Timer
+	firstSeen bool
+	// Commutative messages handled concurrently, see WithCommutativeProperty
+	commutative *commutativeGroup
+
+	// Checkpoint of the session and messages skipped below it, see WithMinSequenceNumber
+	checkpoint    int64
+	checkpointSet bool
+	skipped       int
+
+	// Sequence number of the last message completed in this visit of the session, see WithInvariantChecks
+	lastCompleted int64
+
+	// Handler invocations in progress, several at a time with WithCommutativeProperty. They let the watchdog tell a
+	// hung handler from an idle session, and are all cancelled when the session lock is lost.
+	invocations map[*invocation]struct{}
+
+	// Consecutive watchdog checks that found the session stale, only used by the watchdog
+	staleChecks int
+
+	// Set on shutdown: the session takes no further messages, see drain
+	draining bool
+}
+
+// Heartbeat signals that the handler processing the message in ctx is still making progress. It refreshes the
+// session's liveness timestamp so the watchdog does not expire the session and renews the session lock.
+// Handlers whose work on a single message can outlast the idle timeout should call it periodically, e.g. after
+// each step of a multi step operation. Calling it outside of a handler is a no-op.
+func Heartbeat(ctx context.Context) error {
+	if beat, ok := ctx.Value(heartbeatKey{}).(func() error); ok {
+		return beat()
+	}
+	return nil
+}
+
+// Read last processed time in thread safe manner
+func (sh *StepSessionHandler) GetLastProcessedAt() time.Time {
+	sh.RLock()
+	defer sh.RUnlock()
+	return sh.lastProcessedAt
+}
+
+// Write last processed time in thread safe manner
+func (sh *StepSessionHandler) SetLastProcessedAt(timestamp time.Time) {
+	sh.Lock()
+	sh.lastProcessedAt = timestamp
+	sh.lastActive = sh.convoy.monotonicAt(timestamp)
+	sh.Unlock()
+}
+
+// idleFor returns how long the session has gone without activity, measured on the monotonic clock
+func (sh *StepSessionHandler) idleFor() time.Duration {
+	sh.RLock()
+	defer sh.RUnlock()
+	return sh.convoy.monotonicNow() - sh.lastActive
+}
+
+// currentSessionID returns the ID of the session in progress in thread safe manner
+func (sh *StepSessionHandler) currentSessionID() string {
+	sh.RLock()
+	defer sh.RUnlock()
+	return sh.sessionID
+}
+
+// session returns the message session in thread safe manner
+func (sh *StepSessionHandler) session() lockedSession {
+	sh.RLock()
+	defer sh.RUnlock()
+	return sh.messageSession
+}
+
+// End is called when a session is terminated. Calls without a matching Start are ignored.
+func (sh *StepSessionHandler) End() {
+	if err := sh.awaitCommutative(); err != nil {
+		sh.convoy.logf("❗ Failed to handle commutative messages of session: %v", err)
+	}
+	if err := sh.awaitSettlement(0); err != nil {
+		sh.convoy.logf("❗ Failed to settle last messages of session: %v", err)
+	}
+
+	sh.Lock()
+	if !sh.started || sh.ended {
+		sh.Unlock()
+		return
+	}
+	sh.ended = true
+	close(sh.stopRenew)
+	if sh.empty != nil {
+		sh.empty.Stop()
+	}
+	sessionID, processed, elapsed := sh.sessionID, sh.processed, time.Since(sh.startedAt)
+	skipped, checkpoint := sh.skipped, sh.checkpoint
+	sh.Unlock()
+
+	if skipped > 0 {
+		sh.convoy.logf("⏩ Skipped %d messages of session %s below sequence number %d.", skipped, sessionID, checkpoint)
+	}
+
+	sh.convoy.trackSession(sh, false)
+	sh.convoy.metrics.SetGauge(metricActiveSessions, float64(atomic.AddInt64(&sh.convoy.activeSessions, -1)))
+	sh.convoy.metrics.Observe(metricSessionDepth, float64(processed))
+	sh.convoy.metrics.Observe(metricSessionDuration, elapsed.Seconds())
+	sh.stats.addHold(elapsed)
+	sh.convoy.logf("End session %s. Processed %d messages in %v.", sessionID, processed, elapsed)
+	sh.convoy.emit(Event{Kind: EventSessionEnded, SessionID: sessionID, Latency: elapsed})
+}
+
+// Start is called when a new session is started. A repeated Start for the session already in progress is ignored,
+// while a Start for another session begins its bookkeeping afresh.
+func (sh *StepSessionHandler) Start(ms *servicebus.MessageSession) error {
+	return sh.start(ms)
+}
+
+// start begins the bookkeeping of session ms
+func (sh *StepSessionHandler) start(ms lockedSession) error {
+	sh.Lock()
+	if sh.started && !sh.ended && sh.messageSession == ms {
+		sh.Unlock()
+		sh.convoy.logf("❗ Ignoring duplicate start of session.")
+		return nil
+	}
+
+	restarted := sh.started && !sh.ended
+	if restarted {
+		close(sh.stopRenew)
+		if sh.empty != nil {
+			sh.empty.Stop()
+		}
+	}
+	sh.stopRenew = make(chan struct{})
+	if !sh.convoy.synchronous {
+		go sh.renewSessionLock(sh.stopRenew)
+	}
+
+	sh.messageSession = ms
+	sh.started = true
+	sh.ended = false
+	sh.sessionID = ""
+	if id := ms.SessionID(); id != nil {
+		sh.sessionID = *id
+	}
+	sh.processed = 0
+	sh.startedAt = time.Now()
+	// The idle clock starts when the session is accepted, not when the receiver started waiting for one
+	sh.lastProcessedAt = sh.startedAt
+	sh.lastActive = sh.convoy.monotonicAt(sh.startedAt)
+	sh.released = false
+	sh.received = false
+	sh.lastCompleted = 0
+	sh.firstSeen = false
+	sh.checkpointSet = false
+	sh.skipped = 0
+	sh.empty = sh.watchEmpty()
+	if sh.convoy.commutativeProperty != "" {
+		sh.commutative = newCommutativeGroup(sh.convoy.commutativeParallel)
+	}
+	sh.Unlock()
+
+	sh.stats.addSession()
+	sh.convoy.trackSession(sh, true)
+	sh.convoy.emit(Event{Kind: EventSessionStarted, SessionID: sh.currentSessionID()})
+	if !restarted {
+		sh.convoy.metrics.SetGauge(metricActiveSessions, float64(atomic.AddInt64(&sh.convoy.activeSessions, 1)))
+	}
+	sh.convoy.logf("Begin session")
+	return nil
+}
+
+// recordMessage records the session ID from the first message of the session and counts settled messages
+func (sh *StepSessionHandler) recordMessage(msg *servicebus.Message, settled bool) {
+	sh.Lock()
+	defer sh.Unlock()
+
+	if sh.sessionID == "" {
+		sh.sessionID = sessionIDOf(msg)
+	}
+	if settled {
+		sh.processed++
+	}
+}
+
+// Handle is called when a new session message is received
+func (sh *StepSessionHandler) Handle(ctx context.Context, msg *servicebus.Message) error {
+	if sh.convoy.commutativeProperty != "" {
+		return sh.handleCommutative(ctx, msg)
+	}
+	return sh.handleSafely(ctx, msg)
+}
+
+// handleSafely handles msg, turning a panic into an error wrapping ErrHandlerPanic
+func (sh *StepSessionHandler) handleSafely(ctx context.Context, msg *servicebus.Message) (err error) {
+	defer sh.convoy.recoverPanic(ErrHandlerPanic, "handling message", &err)
+	return sh.handle(ctx, msg)
+}
+
+// handle processes and settles a single message
+func (sh *StepSessionHandler) handle(ctx context.Context, msg *servicebus.Message) error {
+	receivedAt := time.Now()
+	sh.Lock()
+	sh.received = true
+	sh.Unlock()
+	sh.convoy.markActivity()
+
+	if !sh.accept(msg) {
+		return nil
+	}
+
+	// Pre-processing and decoding run while the settlement of the previous message may still be in flight
+	in, preErr := sh.preProcess(ctx, msg)
+	var decoded interface{}
+	var decodeErr error
+	if sh.convoy.decode != nil && preErr == nil {
+		decoded, decodeErr = safeDecode(sh.convoy.decode, in)
+	}
+
+	// The previous message is settled before this one is handled so that completions stay in order, unless
+	// asynchronous settlement lets a bounded number of completions trail behind the handler
+	if err := sh.awaitSettlement(sh.convoy.asyncDepth); err != nil {
+		return err
+	}
+	if sh.isReleased() {
+		return nil
+	}
+
+	var size int64
+	if budget := sh.convoy.inFlight; budget != nil {
+		size = int64(len(msg.Data))
+		if err := budget.acquire(ctx, size); err != nil {
+			return err
+		}
+	}
+
+	sh.SetLastProcessedAt(time.Now())
+	if order := sh.convoy.globalOrder; order != nil {
+		order.register(msg)
+	}
+	ctx = sh.convoy.withCorrelation(ctx, msg)
+	ctx = context.WithValue(ctx, sessionKey{}, sh.session())
+	ctx = context.WithValue(ctx, settledKey{}, &settleNotifier{})
+	ctx = context.WithValue(ctx, heartbeatKey{}, func() error {
+		return sh.heartbeat(ctx)
+	})
+
+	sh.processingStarted(ctx, msg)
+
+	key, hasKey := sh.convoy.idempotencyKey(msg)
+	var st settlement
+	switch {
+	case msg.SessionID == nil:
+		// A message without session ID can only reach a session receiver through misconfiguration
+		sh.convoy.msgLogf(ctx, "❗ Message %s has no session ID. Dead-lettering it.", msg.ID)
+		st, hasKey = settlementFor(&ErrDeadLetter{Reason: "missing session id", Description: "message received without a session ID"}), false
+	case sh.belowCheckpoint(msg):
+		st, hasKey = settlementFor(nil), false
+	case sh.convoy.isExpired(msg):
+		sh.convoy.msgLogf(ctx, "⌛ Message %s expired before it was handled. Skipping it.", msg.ID)
+		st, hasKey = settlementFor(nil), false
+	case hasKey && sh.convoy.idempotencyStore.Seen(key):
+		sh.convoy.msgLogf(ctx, "↪ Message with idempotency key %s already processed. Skipping it.", key)
+		st, hasKey = settlementFor(nil), false
+	case preErr != nil:
+		sh.convoy.msgLogf(ctx, "❗ Failed to pre-process message: %v", preErr)
+		st = settlementFor(&ErrDeadLetter{Reason: "PreProcessFailed", Description: preErr.Error()})
+	case decodeErr != nil:
+		sh.convoy.msgLogf(ctx, "❗ Failed to decode message: %v", decodeErr)
+		st = settlementFor(decodeFailed(decodeErr))
+	default:
+		if sh.convoy.decode != nil {
+			ctx = context.WithValue(ctx, decodedKey{}, decoded)
+		}
+		ctx = sh.convoy.withAttempt(ctx, msg)
+		handlerCtx, cancelExpiry := sh.convoy.withExpiry(ctx, msg)
+		defer cancelExpiry()
+		if first, failed := sh.firstMessage(handlerCtx, msg); failed {
+			st = first
+			break
+		}
+		st = sh.unroutableReply(ctx, msg, settlementFor(sh.runTransactional(handlerCtx, in)))
+		st = sh.skipFailed(ctx, msg, sh.retryLater(ctx, msg, st))
+	}
+
+	cleanup := func() {
+		if size > 0 {
+			sh.convoy.inFlight.release(size)
+		}
+		if order := sh.convoy.globalOrder; order != nil {
+			order.done(msg)
+		}
+	}
+	finish := func() error {
+		defer cleanup()
+		err := sh.finish(ctx, msg, st, key, hasKey)
+		latency := time.Since(receivedAt)
+		sh.convoy.recordLatency(latency)
+		sh.processingFinished(ctx, msg, st.outcome, latency, firstErr(err, st.err))
+		return err
+	}
+	// A message left unsettled because an earlier settlement failed is reported to its consumer as released
+	abort := func(err error) {
+		cleanup()
+		notifySettled(ctx, OutcomeReleased, err)
+	}
+	if sh.settlesAsync(st) {
+		sh.settleAsync(finish, abort)
+		return nil
+	}
+
+	// Settlements still in flight precede this one
+	if err := sh.awaitSettlement(0); err != nil {
+		abort(err)
+		sh.processingFinished(ctx, msg, OutcomeReleased, time.Since(receivedAt), err)
+		return err
+	}
+	return finish()
+}
+
+// runHandler invokes the handler, bounded by the handler timeout if one is configured. A handler cancelled by the
+// convoy, because the watchdog found it hung or the session lock was lost, returns the cause of its cancellation.
+func (sh *StepSessionHandler) runHandler(ctx context.Context, msg *servicebus.Message) error {
+	if sh.convoy.handlerTimeout > 0 {
+		var timeoutCancel context.CancelFunc
+		ctx, timeoutCancel = context.WithTimeout(ctx, sh.convoy.handlerTimeout)
+		defer timeoutCancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	inv := &invocation{startedAt: time.Now(), cancel: cancel}
+	sh.Lock()
+	if sh.invocations == nil {
+		sh.invocations = make(map[*invocation]struct{})
+	}
+	sh.invocations[inv] = struct{}{}
+	sh.Unlock()
+
+	var err error
+	if pool := sh.convoy.pool; pool != nil {
+		err = pool.run(ctx, func() error {
+			sh.SetLastProcessedAt(time.Now())
+			return sh.callHandler(ctx, msg)
+		})
+	} else {
+		err = sh.callHandler(ctx, msg)
+	}
+
+	sh.Lock()
+	delete(sh.invocations, inv)
+	cause := inv.cause
+	sh.Unlock()
+
+	if cause != nil {
+		return cause
+	}
+	return err
+}
+
+// callHandler calls the handler of the session, turning a panic into an error wrapping ErrHandlerPanic so that it is
+// recovered on the goroutine it happened on, e.g. a worker of the handler pool
+func (sh *StepSessionHandler) callHandler(ctx context.Context, msg *servicebus.Message) (err error) {
+	defer sh.convoy.recoverPanic(ErrHandlerPanic, "running handler", &err)
+	return sh.handlerFunc()(ctx, msg)
+}
+
+// handlerFunc returns the handler of the session
+func (sh *StepSessionHandler) handlerFunc() HandlerFunc {
+	if sh.handler != nil {
+		return sh.handler
+	}
+	return sh.convoy.handler
+}
+
+// processedCount returns the number of messages settled in the session
+func (sh *StepSessionHandler) processedCount() int {
+	sh.RLock()
+	defer sh.RUnlock()
+	return sh.processed
+}
+
+// invocation is a handler call in progress
+type invocation struct {
+	startedAt time.Time
+	cancel    context.CancelFunc
+	cause     error
+}
+
+// processingSince returns when the oldest running handler invocation started and whether one is running
+func (sh *StepSessionHandler) processingSince() (time.Time, bool) {
+	sh.RLock()
+	defer sh.RUnlock()
+
+	var oldest time.Time
+	for inv := range sh.invocations {
+		if oldest.IsZero() || inv.startedAt.Before(oldest) {
+			oldest = inv.startedAt
+		}
+	}
+	return oldest, len(sh.invocations) > 0
+}
+
+// cancelRunning cancels every running handler invocation, which then returns cause. It reports false if no handler is
+// running or all were already cancelled.
+func (sh *StepSessionHandler) cancelRunning(cause error) bool {
+	sh.Lock()
+	defer sh.Unlock()
+
+	cancelled := false
+	for inv := range sh.invocations {
+		if inv.cause != nil {
+			continue
+		}
+		inv.cause = cause
+		inv.cancel()
+		cancelled = true
+	}
+	return cancelled
+}
+
+// accept reports whether msg should be processed by this convoy, releasing its session otherwise
+func (sh *StepSessionHandler) accept(msg *servicebus.Message) bool {
+	if sh.isReleased() {
+		return false
+	}
+
+	if sh.stopping() {
+		sh.release()
+		return false
+	}
+
+	if sh.targeted {
+		return true
+	}
+
+	if !sh.convoy.ownsSession(sessionIDOf(msg)) {
+		sh.convoy.logf("↪ Session %s belongs to another shard. Releasing it.", sessionIDOf(msg))
+		sh.release()
+		return false
+	}
+
+	if b := sh.convoy.breaker; b != nil && !b.allow(sessionIDOf(msg)) {
+		sh.convoy.logf("⛔ Circuit open for session %s. Releasing it until cooldown ends.", sessionIDOf(msg))
+		sh.release()
+		return false
+	}
+
+	return true
+}
+
+// finish settles the message and applies the consequences of its settlement
+func (sh *StepSessionHandler) finish(ctx context.Context, msg *servicebus.Message, st settlement, key string, hasKey bool) error {
+	err := sh.settle(ctx, msg, st)
+	notifySettled(ctx, st.outcome, err)
+	if err != nil {
+		return sh.settleFailed(ctx, msg, err)
+	}
+
+	if hasKey && st.outcome == OutcomeCompleted {
+		sh.convoy.idempotencyStore.Commit(key)
+	}
+	if st.outcome != OutcomeAbandoned && st.outcome != OutcomeReleased {
+		sh.convoy.attempts.forget(msg)
+	}
+
+	if b := sh.convoy.breaker; b != nil {
+		switch st.outcome {
+		case OutcomeCompleted:
+			b.success(sessionIDOf(msg))
+		case OutcomeAbandoned:
+			if b.failure(sessionIDOf(msg)) {
+				sh.openCircuit(msg)
+				return st.err
+			}
+		}
+	}
+
+	if sh.stopping() && !st.release {
+		sh.release()
+		return st.err
+	}
+
+	if st.release {
+		if st.outcome == OutcomeReleased {
+			sh.convoy.msgLogf(ctx, "➰ Handler cancelled. Releasing session with message unsettled.")
+		} else {
+			sh.convoy.msgLogf(ctx, "➰ Releasing session to retry message later.")
+		}
+		sh.release()
+		return st.err
+	}
+
+	if st.err == nil && !sh.visitExhausted(ctx) {
+		sh.holdExhausted(ctx)
+	}
+	return st.err
+}
+
+// release closes the session so the broker can hand it out again. Messages the SDK still delivers before the receiver
+// shuts down are left unsettled, which returns them to the session in their original order.
+func (sh *StepSessionHandler) release() {
+	sh.Lock()
+	sh.released = true
+	ms := sh.messageSession
+	sh.Unlock()
+
+	ms.Close()
+}
+
+// isReleased reports whether the session was released by the convoy
+func (sh *StepSessionHandler) isReleased() bool {
+	sh.RLock()
+	defer sh.RUnlock()
+	return sh.released
+}
+
+// settle applies the settlement to the message, retrying transient failures, and records it in the audit sink
+func (sh *StepSessionHandler) settle(ctx context.Context, msg *servicebus.Message, st settlement) error {
+	if st.outcome == OutcomeReleased {
+		return nil
+	}
+	if err := sh.validateLock(ctx, msg); err != nil {
+		return err
+	}
+
+	if st.reply != nil {
+		st = sh.sendReply(ctx, msg, st)
+	}
+
+	// The outcome recorded for a forwarded message stays dead-lettered even if the original is completed
+	outcome := st.outcome
+	if outcome == OutcomeDeadLettered && sh.convoy.forward != nil {
+		st = sh.forwardDeadLetter(ctx, msg, st)
+	}
+
+	if st.outcome == OutcomeDeadLettered {
+		st.properties = sh.convoy.deadLetterProperties(ctx, msg)
+	}
+	if order := sh.convoy.globalOrder; order != nil && st.outcome == OutcomeCompleted {
+		if err := order.await(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	backoff := sh.convoy.settleBackoff
+	for attempt := 1; ; attempt++ {
+		err := sh.convoy.source.settle(ctx, msg, st)
+		if err == nil {
+			break
+		}
+		class := sh.convoy.classify(err)
+		if class == ErrorLockLost {
+			return sh.explainLockLost(ctx, msg, err)
+		}
+		if class == ErrorConnection || sh.isReleased() {
+			// The link of the session is gone, so retrying cannot settle the message
+			return err
+		}
+		if class == ErrorFatal || attempt == sh.convoy.settleAttempts {
+			return err
+		}
+
+		if class == ErrorThrottle {
+			if err = sh.convoy.throttled(ctx, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sh.convoy.msgLogf(ctx, "❗ Failed to settle message (attempt %d of %d), retrying in %v: %v", attempt, sh.convoy.settleAttempts, backoff, err)
+		if err := sleepCtx(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+
+	if st.outcome == OutcomeCompleted {
+		sh.checkCompletionOrder(msg)
+		sh.convoy.completed()
+	}
+	if st.outcome == OutcomeDeferred {
+		sh.convoy.deferred.add(sessionIDOf(msg), sequenceOf(msg))
+	}
+	sh.stats.addMessage()
+	sh.stats.addOutcome(st.outcome)
+	sh.convoy.metrics.IncCounter(metricMessagesProcessed)
+	sh.recordMessage(msg, true)
+	if sh.convoy.audit != nil {
+		sh.convoy.audit.Record(newAuditRecord(msg, outcome))
+	}
+
+	return nil
+}
+
+// settleFailed handles a message that could not be settled. When the lock was lost, the connection dropped or the
+// session was closed underneath the handler, e.g. by the watchdog, the message is redelivered by the broker in order
+// on a later accept, so the session is released and the convoy carries on. Any other failure stops the convoy.
+func (sh *StepSessionHandler) settleFailed(ctx context.Context, msg *servicebus.Message, err error) error {
+	class := sh.convoy.classify(err)
+	released := sh.isReleased()
+	if class != ErrorLockLost && class != ErrorConnection && !released {
+		return err
+	}
+
+	switch {
+	case released:
+		sh.convoy.msgLogf(ctx, "❗ Session %s was closed while handling message %s (sequence number %d). It will be redelivered in order: %v", sessionIDOf(msg), msg.ID, sequenceOf(msg), err)
+	case class == ErrorConnection:
+		sh.convoy.msgLogf(ctx, "❗ Connection lost while settling message %s of session %s. Releasing session for redelivery: %v", msg.ID, sessionIDOf(msg), err)
+	default:
+		sh.convoy.msgLogf(ctx, "❗ Lock lost while settling message %s. Releasing session for redelivery: %v", msg.ID, err)
+	}
+	sh.convoy.metrics.IncCounter(metricLockLost)
+	if b := sh.convoy.breaker; b != nil && b.failure(sessionIDOf(msg)) {
+		sh.openCircuit(msg)
+		return nil
+	}
+
+	sh.release()
+	return nil
+}
+
+// openCircuit releases the session of msg after its circuit breaker opened
+func (sh *StepSessionHandler) openCircuit(msg *servicebus.Message) {
+	sh.convoy.logf("⛔ Session %s failed %d times in a row. Opening circuit for %v.", sessionIDOf(msg), sh.convoy.breaker.threshold, sh.convoy.breaker.cooldown)
+	sh.convoy.metrics.IncCounter(metricCircuitOpened)
+	sh.release()
+}
+
+// sessionIDOf returns the session ID of msg or an empty string if it has none
+func sessionIDOf(msg *servicebus.Message) string {
+	if msg.SessionID == nil {
+		return ""
+	}
+	return *msg.SessionID
+}
+
+// heartbeat refreshes the last processed time and renews the lock on the current session
+func (sh *StepSessionHandler) heartbeat(ctx context.Context) error {
+	sh.SetLastProcessedAt(time.Now())
+	if err := sh.session().RenewLock(ctx); err != nil {
+		sh.convoy.msgLogf(ctx, "❗ Failed to renew session lock: %v", err)
+		return err
+	}
+
+	return nil
+}
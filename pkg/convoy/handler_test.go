@@ -0,0 +1,64 @@
+package convoy
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// newFakeSession returns an open lock on session id
+func newFakeSession(id string) *fakeSession {
+	return &fakeSession{id: id, lockedUntil: time.Now().Add(time.Minute), closed: make(chan struct{})}
+}
+
+// countEvents returns the number of events of kind recorded by c
+func countEvents(c *Convoy, kind EventKind) int {
+	n := 0
+	for _, e := range c.recent.snapshot() {
+		if e.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSessionLifecycleToleratesDuplicateAndOutOfOrderCalls(t *testing.T) {
+	c := newTestConvoy(t, newFakeBroker(), nopHandler, withSynchronousMode())
+	sh := &StepSessionHandler{convoy: c, stats: &runStats{}}
+	active := func() int64 { return atomic.LoadInt64(&c.activeSessions) }
+
+	// An End without Start is ignored
+	sh.End()
+	if n := countEvents(c, EventSessionEnded); n != 0 || active() != 0 {
+		t.Fatalf("End before Start emitted %d events and left %d active sessions", n, active())
+	}
+
+	// A repeated Start of the same session is ignored
+	a := newFakeSession("a")
+	for i := 0; i < 2; i++ {
+		if err := sh.start(a); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+	}
+	sh.recordMessage(servicebus.NewMessageFromString("1"), true)
+	if n := countEvents(c, EventSessionStarted); n != 1 || active() != 1 || sh.processedCount() != 1 {
+		t.Errorf("duplicate Start: %d start events, %d active sessions, %d processed, want 1, 1 and 1", n, active(), sh.processedCount())
+	}
+
+	// A Start of another session without End begins the bookkeeping afresh
+	if err := sh.start(newFakeSession("b")); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if id := sh.currentSessionID(); id != "b" || sh.processedCount() != 0 || active() != 1 {
+		t.Errorf("out of order Start: session %q, %d processed, %d active sessions, want b, 0 and 1", id, sh.processedCount(), active())
+	}
+
+	// Repeated Ends report the session once
+	sh.End()
+	sh.End()
+	if n := countEvents(c, EventSessionEnded); n != 1 || active() != 0 {
+		t.Errorf("duplicate End: %d end events and %d active sessions, want 1 and 0", n, active())
+	}
+}
@@ -0,0 +1,47 @@
+package convoy
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// WithMaxSessionHoldTime releases a session once it was held for d, from its accept, even if it still has messages, so
+// that in a deployment of several convoys no instance holds on to a session far longer than the others. The session
+// returns to the pool of available sessions and resumes in order with its next message on its next accept. The limit
+// is checked after each settled message, so a handler running past it finishes first. Combined with
+// WithMaxMessagesPerSessionVisit the session is released at whichever limit it reaches first.
+func WithMaxSessionHoldTime(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.maxHoldTime = d
+	}
+}
+
+// holdExhausted releases the session once it was held for the maximum hold time and reports whether it did
+func (sh *StepSessionHandler) holdExhausted(ctx context.Context) bool {
+	if sh.convoy.maxHoldTime <= 0 {
+		return false
+	}
+
+	sh.RLock()
+	held := time.Since(sh.startedAt)
+	sh.RUnlock()
+	if held < sh.convoy.maxHoldTime {
+		return false
+	}
+
+	sh.convoy.msgLogf(ctx, "🔁 Held session for %v. Releasing it to give other receivers a turn.", held.Round(time.Millisecond))
+	sh.release()
+	return true
+}
+
+// addHold records that a session was held for d, from its accept to its release
+func (s *runStats) addHold(d time.Duration) {
+	atomic.AddInt64(&s.holdTime, int64(d))
+	for {
+		max := atomic.LoadInt64(&s.maxHoldTime)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&s.maxHoldTime, max, int64(d)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package convoy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestMaxSessionHoldTimeReleasesSession(t *testing.T) {
+	const limit = 50 * time.Millisecond
+	broker := newFakeBroker()
+	broker.add("a", "1", "2", "3", "4")
+
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		time.Sleep(limit * 3 / 5)
+		return nil
+	}, WithMaxSessionHoldTime(limit))
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// The limit is reached when the second message of each visit is settled
+	if summary.Sessions != 2 {
+		t.Errorf("%d sessions, want the session released after two of its messages each visit", summary.Sessions)
+	}
+	if want := []string{"a-1", "a-2", "a-3", "a-4"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if summary.MaxHoldTime < limit {
+		t.Errorf("max hold time %v, want at least the limit of %v", summary.MaxHoldTime, limit)
+	}
+	if summary.HoldTime < 2*limit {
+		t.Errorf("hold time %v, want at least %v", summary.HoldTime, 2*limit)
+	}
+}
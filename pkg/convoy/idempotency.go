@@ -0,0 +1,66 @@
+package convoy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// IdempotencyStore remembers the idempotency keys of messages that were processed successfully. Seen is checked before
+// the handler runs and Commit is called once the message is completed. A persistent store, e.g. a database table with
+// the key as primary key, makes the check survive restarts and can be shared by all convoy instances. Implementations
+// must be safe for concurrent use.
+type IdempotencyStore interface {
+	Seen(key string) bool
+	Commit(key string)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that keeps keys in memory for the lifetime of the process
+type MemoryIdempotencyStore struct {
+	sync.RWMutex
+	keys map[string]struct{}
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{keys: make(map[string]struct{})}
+}
+
+// Seen reports whether key was committed
+func (s *MemoryIdempotencyStore) Seen(key string) bool {
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+// Commit records key as processed
+func (s *MemoryIdempotencyStore) Commit(key string) {
+	s.Lock()
+	s.keys[key] = struct{}{}
+	s.Unlock()
+}
+
+// WithIdempotencyKey skips messages whose application property propertyName holds a key already committed to store.
+// Skipped messages are completed without invoking the handler. Messages without the property are always processed.
+func WithIdempotencyKey(propertyName string, store IdempotencyStore) Option {
+	return func(c *Convoy) {
+		c.idempotencyProperty = propertyName
+		c.idempotencyStore = store
+	}
+}
+
+// idempotencyKey returns the idempotency key of msg, if the option is enabled and the message carries one
+func (c *Convoy) idempotencyKey(msg *servicebus.Message) (string, bool) {
+	if c.idempotencyStore == nil {
+		return "", false
+	}
+
+	v, ok := msg.UserProperties[c.idempotencyProperty]
+	if !ok || v == nil {
+		return "", false
+	}
+
+	return fmt.Sprint(v), true
+}
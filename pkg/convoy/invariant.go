@@ -0,0 +1,49 @@
+package convoy
+
+import (
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// metricInvariantViolations counts violations found by WithInvariantChecks
+const metricInvariantViolations = "convoy_invariant_violations_total"
+
+// WithInvariantChecks verifies that the messages of a session are completed in order: each completed message must
+// have a higher sequence number than the one completed before it in the same session. A violation means the convoy
+// itself broke the ordering guarantee. It panics in builds with the convoy_debug tag, so tests catch ordering
+// regressions, and is logged as an error and counted in production builds.
+func WithInvariantChecks() Option {
+	return func(c *Convoy) {
+		c.invariantChecks = true
+	}
+}
+
+// checkCompletionOrder records the completion of msg and verifies that it follows the last completed message
+func (sh *StepSessionHandler) checkCompletionOrder(msg *servicebus.Message) {
+	if !sh.convoy.invariantChecks {
+		return
+	}
+
+	// Commutative messages may complete in any order among themselves
+	seq := sequenceOf(msg)
+	if seq == 0 || sh.convoy.isCommutative(msg) {
+		return
+	}
+	sh.Lock()
+	last := sh.lastCompleted
+	if seq > last {
+		sh.lastCompleted = seq
+	}
+	sh.Unlock()
+	if seq > last {
+		return
+	}
+
+	violation := fmt.Sprintf("message %d of session %s completed after message %d", seq, sessionIDOf(msg), last)
+	sh.convoy.metrics.IncCounter(metricInvariantViolations)
+	if debugBuild {
+		panic("convoy: ordering invariant violated: " + violation)
+	}
+	sh.convoy.logf("🚨 ORDERING INVARIANT VIOLATED: %s", violation)
+}
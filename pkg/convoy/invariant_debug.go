@@ -0,0 +1,6 @@
+//go:build convoy_debug
+
+package convoy
+
+// debugBuild makes invariant violations panic
+const debugBuild = true
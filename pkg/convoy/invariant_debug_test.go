@@ -0,0 +1,23 @@
+//go:build convoy_debug
+
+package convoy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletionOrderViolationPanics(t *testing.T) {
+	broker := newFakeBroker()
+	msgs := broker.add("a", "1", "2")
+	sh := &StepSessionHandler{convoy: newTestConvoy(t, broker, nopHandler, WithInvariantChecks())}
+
+	sh.checkCompletionOrder(msgs[1])
+	defer func() {
+		r := recover()
+		if s, ok := r.(string); !ok || !strings.Contains(s, "ordering invariant violated") {
+			t.Errorf("completing message 1 after message 2 recovered %v, want an invariant violation", r)
+		}
+	}()
+	sh.checkCompletionOrder(msgs[0])
+}
@@ -0,0 +1,80 @@
+package convoy
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// sameEnqueuedTime gives msgs the same enqueued time, leaving their sequence numbers to order them
+func sameEnqueuedTime(msgs []*servicebus.Message) {
+	enqueued := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, msg := range msgs {
+		msg.SystemProperties.EnqueuedTime = &enqueued
+	}
+}
+
+func TestCompletionOrderInOrder(t *testing.T) {
+	broker := newFakeBroker()
+	msgs := broker.add("a", "1", "2", "3")
+	var logs bytes.Buffer
+	sh := &StepSessionHandler{convoy: newTestConvoy(t, broker, nopHandler, WithInvariantChecks(), WithLogger(log.New(&logs, "", 0)))}
+
+	for _, msg := range msgs {
+		sh.checkCompletionOrder(msg)
+	}
+	if sh.lastCompleted != 3 {
+		t.Errorf("last completed %d, want 3", sh.lastCompleted)
+	}
+	if strings.Contains(logs.String(), "INVARIANT") {
+		t.Errorf("in-order completions reported a violation: %s", logs.String())
+	}
+}
+
+func TestCompletionOrderViolationLogged(t *testing.T) {
+	if debugBuild {
+		t.Skip("violations panic with the convoy_debug tag")
+	}
+
+	broker := newFakeBroker()
+	msgs := broker.add("a", "1", "2")
+	var logs bytes.Buffer
+	sh := &StepSessionHandler{convoy: newTestConvoy(t, broker, nopHandler, WithInvariantChecks(), WithLogger(log.New(&logs, "", 0)))}
+
+	sh.checkCompletionOrder(msgs[1])
+	sh.checkCompletionOrder(msgs[0])
+	if !strings.Contains(logs.String(), "ORDERING INVARIANT VIOLATED: message 1 of session a completed after message 2") {
+		t.Errorf("violation not logged: %s", logs.String())
+	}
+}
+
+func TestCompletionOrderBySequenceNumberWithinEnqueuedTime(t *testing.T) {
+	broker := newFakeBroker()
+	msgs := broker.add("a", "1", "2", "3")
+	sameEnqueuedTime(msgs)
+	var logs bytes.Buffer
+	sh := &StepSessionHandler{convoy: newTestConvoy(t, broker, nopHandler, WithInvariantChecks(), WithLogger(log.New(&logs, "", 0)))}
+
+	for _, msg := range msgs {
+		sh.checkCompletionOrder(msg)
+	}
+	if sh.lastCompleted != 3 {
+		t.Errorf("last completed %d, want 3", sh.lastCompleted)
+	}
+	if strings.Contains(logs.String(), "INVARIANT") {
+		t.Errorf("completions in sequence order reported a violation: %s", logs.String())
+	}
+	if debugBuild {
+		return
+	}
+
+	// A lower sequence number completed later is out of order, although all messages were enqueued at the same time
+	sh.checkCompletionOrder(msgs[1])
+	if !strings.Contains(logs.String(), "ORDERING INVARIANT VIOLATED: message 2 of session a completed after message 3") {
+		t.Errorf("violation not logged: %s", logs.String())
+	}
+}
@@ -0,0 +1,73 @@
+package convoy
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// latencyReservoirSize bounds the latency samples kept per interval of WithLatencySummaryLog
+const latencyReservoirSize = 1024
+
+// WithLatencySummaryLog logs the 50th, 95th and 99th percentile of the processing latency every interval, measured from
+// the receipt of a message to its settlement, for insight into tail latencies without a metrics backend. The
+// percentiles are computed over a uniform sample of at most latencyReservoirSize messages processed in the interval,
+// which keeps memory bounded regardless of the throughput. Samples are handed to a goroutine of their own and dropped
+// if it falls behind, so processing never waits for the summary.
+func WithLatencySummaryLog(interval time.Duration) Option {
+	return func(c *Convoy) {
+		c.latencyLog = interval
+	}
+}
+
+// recordLatency hands the latency of a processed message to the summary, if enabled
+func (c *Convoy) recordLatency(d time.Duration) {
+	if c.latencies == nil {
+		return
+	}
+	select {
+	case c.latencies <- d:
+	default:
+	}
+}
+
+// summarizeLatencies collects latency samples and logs their percentiles every interval until ctx is done
+func (c *Convoy) summarizeLatencies(ctx context.Context) {
+	ticker := time.NewTicker(c.latencyLog)
+	defer ticker.Stop()
+
+	reservoir := make([]time.Duration, 0, latencyReservoirSize)
+	seen := 0
+	for {
+		select {
+		case d := <-c.latencies:
+			// Reservoir sampling keeps a uniform sample of the interval's latencies
+			seen++
+			if len(reservoir) < latencyReservoirSize {
+				reservoir = append(reservoir, d)
+			} else if i := rand.Intn(seen); i < latencyReservoirSize {
+				reservoir[i] = d
+			}
+		case <-ticker.C:
+			if seen > 0 {
+				sort.Slice(reservoir, func(i, j int) bool { return reservoir[i] < reservoir[j] })
+				c.logf("⏱ Processing latency of %d messages in the last %v: p50 %v, p95 %v, p99 %v.", seen, c.latencyLog,
+					percentile(reservoir, 50), percentile(reservoir, 95), percentile(reservoir, 99))
+			}
+			reservoir = reservoir[:0]
+			seen = 0
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// percentile returns the p-th percentile of the sorted samples
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Millisecond)
+}
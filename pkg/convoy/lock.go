@@ -0,0 +1,120 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultLockDuration is the lock duration Service Bus assigns to new queues
+const defaultLockDuration = time.Minute
+
+// WithLockDuration sets the lock duration of the queue instead of querying it through the management API, for
+// environments where the credentials do not permit management operations
+func WithLockDuration(d time.Duration) Option {
+	return func(c *Convoy) {
+		c.lockDuration = d
+	}
+}
+
+// resolveLockDuration determines the lock duration of the queue once, falling back to the Service Bus default if the
+// management API cannot be queried. Run and DrainSession may resolve it concurrently, so it is only accessed under
+// settingsMu.
+func (c *Convoy) resolveLockDuration(ctx context.Context) {
+	if c.currentLockDuration() > 0 {
+		return
+	}
+
+	d := defaultLockDuration
+	defer func() {
+		c.settingsMu.Lock()
+		c.lockDuration = d
+		c.settingsMu.Unlock()
+	}()
+
+	qe, err := c.source.describe(ctx)
+	if err != nil {
+		c.logf("❗ Failed to query lock duration of queue, assuming %v: %v", d, err)
+		return
+	}
+	if qe.LockDuration == nil {
+		return
+	}
+
+	parsed, err := parseISO8601Duration(*qe.LockDuration)
+	if err != nil || parsed <= 0 {
+		c.logf("❗ Unexpected lock duration %q, assuming %v", *qe.LockDuration, d)
+		return
+	}
+	d = parsed
+}
+
+// currentLockDuration returns the lock duration of the queue, zero until it is resolved
+func (c *Convoy) currentLockDuration() time.Duration {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.lockDuration
+}
+
+var iso8601Duration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISO8601Duration parses the day-time subset of ISO 8601 durations used by the management API, e.g. PT1M30S
+func parseISO8601Duration(s string) (time.Duration, error) {
+	m := iso8601Duration.FindStringSubmatch(s)
+	if m == nil || s == "P" || s == "PT" {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(v * float64(unit))
+	}
+
+	return d, nil
+}
+
+// renewSessionLock renews the session lock at half the lock duration until stop is closed or a renewal fails, so the
+// session stays locked to this receiver while it is being processed
+func (sh *StepSessionHandler) renewSessionLock(stop <-chan struct{}) {
+	interval := sh.convoy.currentLockDuration() / 2
+	timer := time.NewTicker(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := sh.session().RenewLock(ctx)
+		cancel()
+		if err != nil {
+			sh.renewFailed(err)
+			return
+		}
+	}
+}
+
+// renewFailed aborts the session after its lock could not be renewed. A running handler is cancelled right away since
+// its message can no longer be completed, and the session is released so the broker redelivers the message in order.
+func (sh *StepSessionHandler) renewFailed(err error) {
+	sh.convoy.metrics.IncCounter(metricLockRenewalFailed)
+	if sh.cancelRunning(errLockLost) {
+		sh.convoy.logf("❗ Failed to renew session lock. Cancelling handler and releasing session for redelivery: %v", err)
+	} else {
+		sh.convoy.logf("❗ Failed to renew session lock. Releasing session: %v", err)
+	}
+	sh.convoy.metrics.IncCounter(metricLockLost)
+	sh.release()
+}
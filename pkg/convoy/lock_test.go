@@ -0,0 +1,119 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "PT1M", want: time.Minute},
+		{in: "PT30S", want: 30 * time.Second},
+		{in: "PT1M30S", want: 90 * time.Second},
+		{in: "PT0.5S", want: 500 * time.Millisecond},
+		{in: "PT2H", want: 2 * time.Hour},
+		{in: "P1D", want: 24 * time.Hour},
+		{in: "P1DT2H3M4S", want: 26*time.Hour + 3*time.Minute + 4*time.Second},
+		{in: "PT0S", want: 0},
+		{in: "", wantErr: true},
+		{in: "P", wantErr: true},
+		{in: "PT", wantErr: true},
+		{in: "1M", wantErr: true},
+		{in: "PT1X", wantErr: true},
+		{in: "PT-1M", wantErr: true},
+		{in: "P1Y", wantErr: true},
+		{in: "PT1M30", wantErr: true},
+		{in: "pt1m", wantErr: true},
+		{in: "PT1.5M", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseISO8601Duration(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseISO8601Duration(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseISO8601Duration(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveLockDurationConcurrently(t *testing.T) {
+	broker := newFakeBroker()
+	lockDuration := "PT30S"
+	broker.description.LockDuration = &lockDuration
+	c := newTestConvoy(t, broker, nopHandler, WithLockDuration(0))
+
+	// Run and DrainSession resolve the lock duration while sessions read it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.resolveLockDuration(context.Background())
+		}()
+		go func() {
+			defer wg.Done()
+			c.currentLockDuration()
+		}()
+	}
+	wg.Wait()
+
+	if got := c.currentLockDuration(); got != 30*time.Second {
+		t.Errorf("lock duration = %v, want 30s", got)
+	}
+}
+
+func TestRenewalFailureCancelsHandlerAndReleasesSession(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	broker.renewErrs = []error{errors.New("session lock lost")}
+
+	var handled []string
+	var cancelled error
+	c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		if msg.DeliveryCount > 1 || msg.ID != "a-1" {
+			return nil
+		}
+		// Outlives the first renewal, which fails
+		select {
+		case <-ctx.Done():
+			cancelled = ctx.Err()
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}, WithLockDuration(40*time.Millisecond))
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if cancelled == nil {
+		t.Fatal("handler not cancelled after the lock renewal failed")
+	}
+	if want := []string{"a-1", "a-1", "a-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	// The message of the cancelled handler is left unsettled and redelivered in order on the next accept
+	if got, want := outcomesOf(broker), []Outcome{OutcomeCompleted, OutcomeCompleted}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+	if summary.Sessions != 2 {
+		t.Errorf("%d sessions, want the session released and accepted again", summary.Sessions)
+	}
+}
@@ -0,0 +1,82 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// metricLockExpired counts settlements that found the lock of their message expired, see WithLockTokenValidation
+const metricLockExpired = "convoy_lock_expired_total"
+
+// ErrLockExpired is returned when a message could not be settled because its lock token is missing or its lock has
+// expired, typically because its handler ran longer than the lock duration without a heartbeat. It is classified as
+// ErrorLockLost, so the session is released and the message redelivered.
+var ErrLockExpired = errors.New("lock token invalid or expired")
+
+// WithLockTokenValidation checks the lock of a message before it is settled and reports an expired lock, or a lock lost
+// while settling, as ErrLockExpired along with how long the message was held, instead of the generic settlement error
+// of the broker. This makes the most common failure of session processing, a handler outlasting its lock, easy to tell
+// apart. The check costs no round trip; it compares the lock expiry known to the receiver with the current time.
+func WithLockTokenValidation() Option {
+	return func(c *Convoy) {
+		c.lockValidation = true
+	}
+}
+
+// validateLock returns ErrLockExpired if msg can no longer be settled by this receiver
+func (sh *StepSessionHandler) validateLock(ctx context.Context, msg *servicebus.Message) error {
+	if !sh.convoy.lockValidation {
+		return nil
+	}
+	if msg.LockToken == nil {
+		return sh.lockExpired(ctx, fmt.Errorf("%w: message %s carries no lock token", ErrLockExpired, msg.ID))
+	}
+
+	until := sh.lockedUntil(msg)
+	if until.IsZero() || time.Now().Before(until) {
+		return nil
+	}
+	return sh.lockExpired(ctx, fmt.Errorf("%w: lock of message %s expired %v ago after holding it for %v", ErrLockExpired,
+		msg.ID, time.Since(until).Round(time.Millisecond), sh.heldFor(msg).Round(time.Millisecond)))
+}
+
+// explainLockLost wraps err, a lock lost while settling msg, in ErrLockExpired if WithLockTokenValidation is set
+func (sh *StepSessionHandler) explainLockLost(ctx context.Context, msg *servicebus.Message, err error) error {
+	if !sh.convoy.lockValidation || errors.Is(err, ErrLockExpired) {
+		return err
+	}
+	return sh.lockExpired(ctx, fmt.Errorf("%w: lock of message %s lost after holding it for %v: %v", ErrLockExpired,
+		msg.ID, sh.heldFor(msg).Round(time.Millisecond), err))
+}
+
+// lockExpired logs and counts an expired lock
+func (sh *StepSessionHandler) lockExpired(ctx context.Context, err error) error {
+	sh.convoy.msgLogf(ctx, "🔓 %v. Consider a longer lock duration or heartbeats in the handler.", err)
+	sh.convoy.metrics.IncCounter(metricLockExpired)
+	return err
+}
+
+// lockedUntil returns when the lock on msg expires: the later of the expiry delivered with the message and the expiry
+// of the session lock after its last renewal
+func (sh *StepSessionHandler) lockedUntil(msg *servicebus.Message) time.Time {
+	var until time.Time
+	if msg.SystemProperties != nil && msg.SystemProperties.LockedUntil != nil {
+		until = *msg.SystemProperties.LockedUntil
+	}
+	if ms := sh.session(); ms != nil && ms.LockedUntil().After(until) {
+		until = ms.LockedUntil()
+	}
+	return until
+}
+
+// heldFor estimates how long msg has been locked from the expiry delivered with it
+func (sh *StepSessionHandler) heldFor(msg *servicebus.Message) time.Duration {
+	if msg.SystemProperties == nil || msg.SystemProperties.LockedUntil == nil {
+		return 0
+	}
+	return time.Since(msg.SystemProperties.LockedUntil.Add(-sh.convoy.currentLockDuration()))
+}
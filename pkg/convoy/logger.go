@@ -0,0 +1,30 @@
+package convoy
+
+import (
+	"log"
+	"os"
+)
+
+// Logger receives the convoy's log output. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger writes the convoy's log output to l instead of timestamped lines on stdout
+func WithLogger(l Logger) Option {
+	return func(c *Convoy) {
+		c.logger = l
+	}
+}
+
+func defaultLogger() Logger {
+	return log.New(os.Stdout, "", log.LstdFlags)
+}
+
+// logf writes a log line through the configured logger, prefixed with the worker identity
+func (c *Convoy) logf(format string, v ...interface{}) {
+	if c.workerID != "" {
+		format, v = "[%s] "+format, append([]interface{}{c.workerID}, v...)
+	}
+	c.logger.Printf(format, v...)
+}
@@ -0,0 +1,64 @@
+package convoy
+
+import (
+	"context"
+	"sync"
+)
+
+// WithMaxInFlightBytes bounds the total size of the message bodies held by the convoy at a time. Once the bound is
+// reached, receiving the next message waits until enough held messages are settled. A single message larger than the
+// bound is still processed on its own so the convoy cannot stall. This bounds aggregate memory across all sessions,
+// not the size of individual messages.
+func WithMaxInFlightBytes(n int64) Option {
+	return func(c *Convoy) {
+		c.inFlight = newByteBudget(n, c)
+	}
+}
+
+// byteBudget accounts for the bytes of messages between receipt and settlement
+type byteBudget struct {
+	mu      sync.Mutex
+	max     int64
+	used    int64
+	changed chan struct{}
+	convoy  *Convoy
+}
+
+func newByteBudget(max int64, c *Convoy) *byteBudget {
+	return &byteBudget{
+		max:     max,
+		changed: make(chan struct{}),
+		convoy:  c,
+	}
+}
+
+// acquire reserves n bytes, waiting until they fit in the budget or ctx is done
+func (b *byteBudget) acquire(ctx context.Context, n int64) error {
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+n <= b.max {
+			b.used += n
+			b.convoy.metrics.SetGauge(metricInFlightBytes, float64(b.used))
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns n bytes to the budget and wakes up waiting receivers
+func (b *byteBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.convoy.metrics.SetGauge(metricInFlightBytes, float64(b.used))
+	close(b.changed)
+	b.changed = make(chan struct{})
+	b.mu.Unlock()
+}
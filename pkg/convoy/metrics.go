@@ -0,0 +1,123 @@
+package convoy
+
+import "sync"
+
+// Names of the metrics reported by the convoy
+const (
+	metricForcedTerminations = "convoy_forced_terminations_total"
+	metricInFlightBytes      = "convoy_in_flight_bytes"
+	metricLockLost           = "convoy_lock_lost_total"
+	metricLockRenewalFailed  = "convoy_lock_renewal_failures_total"
+	metricCircuitOpened      = "convoy_circuit_opened_total"
+	metricSessionExpiries    = "convoy_session_expiries_total"
+	metricThrottled          = "convoy_throttled_total"
+	metricConcurrency        = "convoy_concurrent_sessions"
+	metricMessagesProcessed  = "convoy_messages_processed_total"
+	metricActiveSessions     = "convoy_active_sessions"
+
+	// Observed once per session when it ends: the number of messages settled and the time the session was held
+	metricSessionDepth    = "convoy_session_depth_messages"
+	metricSessionDuration = "convoy_session_duration_seconds"
+)
+
+// Metrics receives the counters, gauges and observations reported by the convoy. Implementations adapt them to a
+// metrics backend and must be safe for concurrent use.
+type Metrics interface {
+	IncCounter(name string)
+	SetGauge(name string, value float64)
+	Observe(name string, value float64)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) IncCounter(string)        {}
+func (nopMetrics) SetGauge(string, float64) {}
+func (nopMetrics) Observe(string, float64)  {}
+
+// metricsQueueSize bounds the metric updates waiting for a slow Metrics implementation before further ones are dropped
+const metricsQueueSize = 1024
+
+// isolatedMetrics reports to the Metrics set with WithMetrics from a goroutine of its own, so that an implementation
+// that blocks or panics never stalls or crashes message processing. Updates are dropped while the queue is full, and
+// drops and panics are logged once rather than on every message.
+type isolatedMetrics struct {
+	next     Metrics
+	updates  chan func(Metrics)
+	quit     chan struct{}
+	quitOnce sync.Once
+	logf     func(format string, v ...interface{})
+
+	dropped  sync.Once
+	panicked sync.Once
+}
+
+func isolateMetrics(next Metrics, logf func(format string, v ...interface{})) *isolatedMetrics {
+	m := &isolatedMetrics{
+		next:    next,
+		updates: make(chan func(Metrics), metricsQueueSize),
+		quit:    make(chan struct{}),
+		logf:    logf,
+	}
+	go m.forward()
+	return m
+}
+
+func (m *isolatedMetrics) IncCounter(name string) {
+	m.enqueue(func(next Metrics) { next.IncCounter(name) })
+}
+
+func (m *isolatedMetrics) SetGauge(name string, value float64) {
+	m.enqueue(func(next Metrics) { next.SetGauge(name, value) })
+}
+
+func (m *isolatedMetrics) Observe(name string, value float64) {
+	m.enqueue(func(next Metrics) { next.Observe(name, value) })
+}
+
+// enqueue hands the update to the forwarding goroutine without ever blocking
+func (m *isolatedMetrics) enqueue(update func(Metrics)) {
+	select {
+	case <-m.quit:
+		return
+	default:
+	}
+
+	select {
+	case m.updates <- update:
+	default:
+		m.dropped.Do(func() {
+			m.logf("❗ Metrics are not keeping up. Dropping metric updates until they do.")
+		})
+	}
+}
+
+// forward applies the queued updates until the convoy is closed
+func (m *isolatedMetrics) forward() {
+	for {
+		select {
+		case update := <-m.updates:
+			m.apply(update)
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// apply runs a single update, recovering a panic of the Metrics implementation
+func (m *isolatedMetrics) apply(update func(Metrics)) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.panicked.Do(func() {
+				m.logf("❗ Metrics panicked, ignoring further panics: %v", r)
+			})
+		}
+	}()
+	update(m.next)
+}
+
+// stop ends the forwarding goroutine. Later updates are dropped.
+func (m *isolatedMetrics) stop() {
+	m.quitOnce.Do(func() {
+		close(m.quit)
+	})
+}
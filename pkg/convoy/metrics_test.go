@@ -0,0 +1,100 @@
+package convoy
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lockedBuffer collects log output written from several goroutines
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// blockingMetrics hangs on every update until released
+type blockingMetrics struct {
+	release chan struct{}
+}
+
+func (m blockingMetrics) IncCounter(string)        { <-m.release }
+func (m blockingMetrics) SetGauge(string, float64) { <-m.release }
+func (m blockingMetrics) Observe(string, float64)  { <-m.release }
+
+// panickingMetrics panics on every update
+type panickingMetrics struct{}
+
+func (panickingMetrics) IncCounter(string)        { panic("metrics backend down") }
+func (panickingMetrics) SetGauge(string, float64) { panic("metrics backend down") }
+func (panickingMetrics) Observe(string, float64)  { panic("metrics backend down") }
+
+func TestFaultyMetricsDoNotStallProcessing(t *testing.T) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	tests := []struct {
+		name    string
+		metrics Metrics
+		logged  string
+	}{
+		{name: "blocking", metrics: blockingMetrics{release: release}, logged: "Metrics are not keeping up"},
+		{name: "panicking", metrics: panickingMetrics{}, logged: "Metrics panicked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker()
+			// Enough messages to overflow the queue of updates waiting for the metrics
+			bodies := make([]string, 2*metricsQueueSize)
+			for i := range bodies {
+				bodies[i] = strconv.Itoa(i)
+			}
+			broker.add("a", bodies...)
+			var logs lockedBuffer
+			c := newTestConvoy(t, broker, nopHandler, WithMetrics(tt.metrics), WithLogger(log.New(&logs, "", 0)))
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := c.RunOnce(context.Background())
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("RunOnce: %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("processing stalled by the metrics")
+			}
+			if n := broker.remaining(); n != 0 {
+				t.Errorf("%d messages left on the broker", n)
+			}
+
+			// The failure is logged once, not for every update
+			deadline := time.Now().Add(time.Second)
+			for !strings.Contains(logs.String(), tt.logged) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if n := strings.Count(logs.String(), tt.logged); n != 1 {
+				t.Errorf("failing metrics logged %d times, want once", n)
+			}
+		})
+	}
+}
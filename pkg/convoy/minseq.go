@@ -0,0 +1,41 @@
+package convoy
+
+import (
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithMinSequenceNumber fast-forwards every session to seq: messages with a lower sequence number are completed without
+// calling the handler, e.g. to resume after a replay from a known checkpoint, and the messages from seq on are handled
+// in order as usual. The number of messages skipped is logged when the session ends. Sequence numbers are unique
+// across the queue, so a single checkpoint applies to all sessions; use WithMinSequenceNumberFunc for a checkpoint per
+// session.
+func WithMinSequenceNumber(seq int64) Option {
+	return WithMinSequenceNumberFunc(func(string) int64 { return seq })
+}
+
+// WithMinSequenceNumberFunc is WithMinSequenceNumber with a checkpoint per session, looked up by fn with the session
+// ID each time a session is accepted, e.g. from a checkpoint store
+func WithMinSequenceNumberFunc(fn func(sessionID string) int64) Option {
+	return func(c *Convoy) {
+		c.minSequence = fn
+	}
+}
+
+// belowCheckpoint reports whether msg precedes the checkpoint of its session and counts it as skipped
+func (sh *StepSessionHandler) belowCheckpoint(msg *servicebus.Message) bool {
+	if sh.convoy.minSequence == nil {
+		return false
+	}
+
+	sh.Lock()
+	defer sh.Unlock()
+	if !sh.checkpointSet {
+		sh.checkpoint = sh.convoy.minSequence(sessionIDOf(msg))
+		sh.checkpointSet = true
+	}
+	if sequenceOf(msg) >= sh.checkpoint {
+		return false
+	}
+	sh.skipped++
+	return true
+}
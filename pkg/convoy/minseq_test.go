@@ -0,0 +1,41 @@
+package convoy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestMinSequenceNumberSkipsInOrder(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2", "3")
+	broker.add("b", "1", "2")
+
+	var handled []string
+	checkpoints := map[string]int64{"a": 3, "b": 0}
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	}, WithMinSequenceNumberFunc(func(sessionID string) int64 { return checkpoints[sessionID] }))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if want := []string{"a-3", "b-1", "b-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+
+	// The messages below the checkpoint are completed in order before the session resumes at the checkpoint
+	var settled []string
+	for _, s := range broker.settlements() {
+		if s.outcome != OutcomeCompleted {
+			t.Errorf("message %s settled as %v, want completed", s.messageID, s.outcome)
+		}
+		settled = append(settled, s.messageID)
+	}
+	if want := []string{"a-1", "a-2", "a-3", "b-1", "b-2"}; !reflect.DeepEqual(settled, want) {
+		t.Errorf("settled %v, want %v", settled, want)
+	}
+}
@@ -0,0 +1,44 @@
+package convoy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-amqp-common-go/v3/conn"
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithNamespaceOptions applies opts when the namespace is built from the connection string, e.g.
+// servicebus.NamespaceWithUserAgent or, for sovereign clouds such as Azure Government or Azure China,
+// servicebus.NamespaceWithAzureEnvironment with the namespace of the connection string. The endpoint suffix of a
+// sovereign cloud is taken from the connection string as well, so the environment must match it. Credentials come from
+// the connection string: options that replace them, such as servicebus.NamespaceWithTokenProvider, are rejected.
+func WithNamespaceOptions(opts ...servicebus.NamespaceOption) Option {
+	return func(c *Convoy) {
+		c.namespaceOptions = append(c.namespaceOptions, opts...)
+	}
+}
+
+// checkNamespaceOptions verifies that the namespace options do not conflict with the connection string
+func checkNamespaceOptions(connStr string, opts []servicebus.NamespaceOption) error {
+	probe, err := servicebus.NewNamespace(opts...)
+	if err != nil {
+		return err
+	}
+	if probe.TokenProvider != nil {
+		return errors.New("namespace options must not set credentials, they are taken from the connection string")
+	}
+
+	parsed, err := conn.ParsedConnectionFromStr(connStr)
+	if err != nil {
+		return err
+	}
+	if probe.Name != "" && probe.Name != parsed.Namespace {
+		return fmt.Errorf("namespace options refer to namespace %q, the connection string to %q", probe.Name, parsed.Namespace)
+	}
+	if probe.Name != "" && parsed.Suffix != "" && probe.Suffix != parsed.Suffix {
+		return fmt.Errorf("namespace options use endpoint suffix %q, the connection string %q", probe.Suffix, parsed.Suffix)
+	}
+
+	return nil
+}
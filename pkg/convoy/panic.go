@@ -0,0 +1,62 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrSDKPanic is wrapped by the error reported for a panic recovered while receiving from or closing a session
+// receiver. It is classified as ErrorConnection, so the convoy reconnects after a backoff.
+var ErrSDKPanic = errors.New("service bus client panicked")
+
+// ErrHandlerPanic is wrapped by the error reported for a panic recovered while handling or settling a message, e.g. of
+// the handler, a hook or the audit sink. It is classified as ErrorFatal: the message is abandoned, so the broker counts
+// the delivery, and the convoy stops rather than reconnecting into the same panic.
+var ErrHandlerPanic = errors.New("message handler panicked")
+
+// WithoutPanicRecovery lets a panic crash the process, for deployments that prefer to fail fast and leave the restart
+// to their supervisor. By default a panic raised while receiving from or closing a session receiver, e.g. of the AMQP
+// layer on a malformed frame, is logged with its stack and handled like a failed connection, and a panic of the
+// handler or of other code run for a message, on any goroutine, is logged and reported as ErrHandlerPanic.
+func WithoutPanicRecovery() Option {
+	return func(c *Convoy) {
+		c.failFast = true
+	}
+}
+
+// recoverPanic turns a panic into an error wrapping sentinel unless recovery is disabled. It must be deferred.
+func (c *Convoy) recoverPanic(sentinel error, op string, err *error) {
+	if c.failFast {
+		return
+	}
+	if r := recover(); r != nil {
+		c.logf("🚫 Recovered panic while %s: %v\n%s", op, r, debug.Stack())
+		*err = fmt.Errorf("%w while %s: %v", sentinel, op, r)
+	}
+}
+
+// isHandlerPanic reports whether err is a recovered panic of code run for a message
+func isHandlerPanic(err error) bool {
+	return errors.Is(err, ErrHandlerPanic)
+}
+
+// receiveSession receives from qs into sess, recovering a panic. After a panic the session is released and ended since
+// the SDK no longer does.
+func (c *Convoy) receiveSession(ctx context.Context, qs sessionReceiver, sess *StepSessionHandler) (err error) {
+	defer func() {
+		if errors.Is(err, ErrSDKPanic) && sess.session() != nil {
+			sess.release()
+			sess.End()
+		}
+	}()
+	defer c.recoverPanic(ErrSDKPanic, "receiving", &err)
+	return qs.ReceiveOne(ctx, sess)
+}
+
+// closeSession closes the session receiver qs, recovering a panic
+func (c *Convoy) closeSession(ctx context.Context, qs sessionReceiver) (err error) {
+	defer c.recoverPanic(ErrSDKPanic, "closing session receiver", &err)
+	return qs.Close(ctx)
+}
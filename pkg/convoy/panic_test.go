@@ -0,0 +1,97 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// panickingAudit is an audit sink that panics on every record
+type panickingAudit struct{}
+
+func (panickingAudit) Record(AuditRecord) { panic("audit sink failed") }
+func (panickingAudit) Close() error       { return nil }
+
+func TestHandlerPanicStopsConvoy(t *testing.T) {
+	panicking := func(context.Context, *servicebus.Message) error {
+		panic("handler failed")
+	}
+	succeeding := func(context.Context, *servicebus.Message) error {
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		handler     HandlerFunc
+		opts        []Option
+		commutative bool
+		abandoned   bool
+	}{
+		{name: "receive loop", handler: panicking, abandoned: true},
+		{name: "handler pool", handler: panicking, opts: []Option{WithHandlerPoolSize(2)}, abandoned: true},
+		{name: "commutative", handler: panicking, opts: []Option{WithCommutativeProperty("commutative", 2)}, commutative: true, abandoned: true},
+		{name: "async settlement", handler: succeeding, opts: []Option{WithAsyncSettlement(1), WithAuditSink(panickingAudit{})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker()
+			msgs := broker.add("a", "1", "2", "3")
+			if tt.commutative {
+				msgs[0].UserProperties = map[string]interface{}{"commutative": true}
+			}
+			c := newTestConvoy(t, broker, tt.handler, tt.opts...)
+
+			_, err := c.RunOnce(context.Background())
+			if !errors.Is(err, ErrHandlerPanic) {
+				t.Fatalf("RunOnce = %v, want %v", err, ErrHandlerPanic)
+			}
+			if class := c.classify(err); class != ErrorFatal {
+				t.Errorf("panic classified as %v, want ErrorFatal", class)
+			}
+
+			if !tt.abandoned {
+				return
+			}
+			settled := broker.settlements()
+			if len(settled) == 0 || settled[0].messageID != "a-1" || settled[0].outcome != OutcomeAbandoned {
+				t.Errorf("settlements %+v, want a-1 abandoned first", settled)
+			}
+		})
+	}
+}
+
+func TestReceivePanicIsRecovered(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	receives := 0
+	broker.receiveErr = func() error {
+		if receives++; receives == 1 {
+			panic("malformed frame")
+		}
+		return nil
+	}
+
+	var acceptErr error
+	c := newTestConvoy(t, broker, nopHandler, WithOnAcceptError(func(err error) AcceptDecision {
+		acceptErr = err
+		// Retrying right away skips the reconnect backoff the default reaction waits for
+		return AcceptRetry
+	}))
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if !errors.Is(acceptErr, ErrSDKPanic) {
+		t.Fatalf("accept failed with %v, want %v", acceptErr, ErrSDKPanic)
+	}
+	if class := c.classify(acceptErr); class != ErrorConnection {
+		t.Errorf("receive panic classified as %v, want ErrorConnection", class)
+	}
+	if summary.Messages != 2 {
+		t.Errorf("processed %d messages after the panic, want 2", summary.Messages)
+	}
+}
@@ -0,0 +1,146 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// DecodeFunc prepares a message for its handler, e.g. by deserializing and validating its body. It must not have
+// side effects since it runs before the previous message of the session is settled.
+type DecodeFunc func(msg *servicebus.Message) (interface{}, error)
+
+type decodedKey struct{}
+
+// WithPipelinedDecode decodes each message with decode before its handler runs, and pipelines the work of consecutive
+// messages: once the handler of a message completed it, the completion is sent in the background while the next
+// message of the session is decoded. The handler of the next message only starts after the completion succeeded, so
+// handlers still run one at a time in session order and each message is completed before the next one is handled. Per
+// message this saves the shorter of the decode time and the settlement round trip, which is significant for small,
+// quick handlers on a distant namespace. A message that cannot be decoded is dead-lettered once the message before it
+// is settled. Outcomes other than completion are settled synchronously, so an abandoned message is redelivered before
+// the next one.
+func WithPipelinedDecode(decode DecodeFunc) Option {
+	return func(c *Convoy) {
+		c.decode = decode
+	}
+}
+
+// safeDecode runs decode and turns a panic on a malformed body into a decode error, so the message is dead-lettered
+// instead of crashing the convoy
+func safeDecode(decode DecodeFunc, msg *servicebus.Message) (v interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, fmt.Errorf("decoder panicked: %v", r)
+		}
+	}()
+	return decode(msg)
+}
+
+// decodeFailed returns the dead-letter of a message that could not be decoded with err
+func decodeFailed(err error) *ErrDeadLetter {
+	return &ErrDeadLetter{Reason: "DecodeFailed", Description: err.Error()}
+}
+
+// Decoded returns the value decoded by the DecodeFunc for the message handled with ctx
+func Decoded(ctx context.Context) interface{} {
+	return ctx.Value(decodedKey{})
+}
+
+// WithAsyncSettlement lets handlers run ahead of the settlement of their messages: once a handler completed its
+// message, the completion is sent in the background and the next message of the session is handled right away, with
+// up to depth completions in flight. Completions are committed strictly in order, one after another, so message N+1
+// is never completed before message N, and handler throughput is no longer gated by the settlement round trip.
+// Outcomes other than completion are settled synchronously after all completions in flight.
+//
+// Handlers must tolerate redelivery even more than usual: if a completion fails, the completions queued after it are
+// dropped, so the broker redelivers the failed message and all messages handled after it, in order. On shutdown the
+// completions in flight are committed before the session is closed.
+func WithAsyncSettlement(depth int) Option {
+	return func(c *Convoy) {
+		c.asyncDepth = depth
+	}
+}
+
+// pendingSettlement is a settlement running in the background
+type pendingSettlement struct {
+	done chan struct{}
+	err  error
+}
+
+// settlesAsync reports whether the settlement of the message is sent in the background
+func (sh *StepSessionHandler) settlesAsync(st settlement) bool {
+	// Any other outcome returns the message to the session or releases it, which must happen before the next message
+	// is delivered to keep the session in order
+	if st.err != nil || st.outcome != OutcomeCompleted {
+		return false
+	}
+	return sh.convoy.asyncDepth > 0 || sh.convoy.decode != nil
+}
+
+// settleAsync runs finish in the background after the settlements already pending in the session. If one of those
+// failed, finish is skipped and abort runs instead with the error, leaving the message to be redelivered.
+func (sh *StepSessionHandler) settleAsync(finish func() error, abort func(error)) {
+	p := &pendingSettlement{done: make(chan struct{})}
+	sh.Lock()
+	var prev *pendingSettlement
+	if n := len(sh.pending); n > 0 {
+		prev = sh.pending[n-1]
+	}
+	sh.pending = append(sh.pending, p)
+	sh.Unlock()
+
+	go func() {
+		defer close(p.done)
+		if prev != nil {
+			<-prev.done
+			if prev.err != nil {
+				p.err = prev.err
+				abort(prev.err)
+				return
+			}
+		}
+		p.err = sh.finishSafely(finish)
+	}()
+}
+
+// finishSafely runs finish, turning a panic into an error wrapping ErrHandlerPanic
+func (sh *StepSessionHandler) finishSafely(finish func() error) (err error) {
+	defer sh.convoy.recoverPanic(ErrHandlerPanic, "settling message", &err)
+	return finish()
+}
+
+// awaitSettlement waits until at most limit settlements of the session are pending and returns the first error of
+// the settlements that finished
+func (sh *StepSessionHandler) awaitSettlement(limit int) error {
+	for {
+		sh.Lock()
+		for len(sh.pending) > 0 && isDone(sh.pending[0].done) {
+			err := sh.pending[0].err
+			sh.pending = sh.pending[1:]
+			if err != nil {
+				sh.Unlock()
+				return err
+			}
+		}
+		if len(sh.pending) <= limit {
+			sh.Unlock()
+			return nil
+		}
+		head := sh.pending[0]
+		sh.Unlock()
+
+		<-head.done
+	}
+}
+
+// isDone reports whether done is closed
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,102 @@
+package convoy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// fuzzDecode decodes a JSON document with a non-empty list of steps and returns its first step. It panics on a
+// document without steps, like a decoder that trusts its input.
+func fuzzDecode(msg *servicebus.Message) (interface{}, error) {
+	var doc struct {
+		Steps []string `json:"steps"`
+	}
+	if err := json.Unmarshal(msg.Data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Steps[0], nil
+}
+
+func addDecodeSeeds(f *testing.F) {
+	for _, seed := range []string{`{"steps":["ship"]}`, `{"steps":[]}`, `{}`, `null`, `{"steps":`, ``, "\xff"} {
+		f.Add([]byte(seed))
+	}
+}
+
+func FuzzSafeDecode(f *testing.F) {
+	addDecodeSeeds(f)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		v, err := safeDecode(fuzzDecode, servicebus.NewMessage(body))
+		if err == nil {
+			if _, ok := v.(string); !ok {
+				t.Fatalf("decoded %v without error, want a step", v)
+			}
+			return
+		}
+
+		st := settlementFor(decodeFailed(err))
+		if st.outcome != OutcomeDeadLettered || st.deadLetter == nil || st.deadLetter.Reason != "DecodeFailed" {
+			t.Fatalf("decode failure %v settled as %+v, want dead-lettered with reason DecodeFailed", err, st)
+		}
+	})
+}
+
+func FuzzPipelinedDecode(f *testing.F) {
+	addDecodeSeeds(f)
+	f.Fuzz(func(t *testing.T, body []byte) {
+		_, decodeErr := safeDecode(fuzzDecode, servicebus.NewMessage(body))
+
+		broker := newFakeBroker()
+		broker.add("a", string(body))
+		var handled interface{}
+		c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+			handled = Decoded(ctx)
+			return nil
+		}, WithPipelinedDecode(fuzzDecode))
+
+		if _, err := c.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		settled := broker.settlements()
+		if len(settled) != 1 {
+			t.Fatalf("settlements %+v, want one", settled)
+		}
+		switch {
+		case decodeErr != nil && (settled[0].outcome != OutcomeDeadLettered || settled[0].reason != "DecodeFailed"):
+			t.Errorf("undecodable body settled as %+v, want dead-lettered with reason DecodeFailed", settled[0])
+		case decodeErr == nil && (settled[0].outcome != OutcomeCompleted || handled == nil):
+			t.Errorf("decodable body settled as %+v with %v handled, want completed", settled[0], handled)
+		}
+	})
+}
+
+func TestPipelinedDecodeRetriesAbandonedMessageFirst(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("s", "a", "b", "c")
+
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, string(msg.Data))
+		if string(msg.Data) == "a" && msg.DeliveryCount == 1 {
+			return ErrAbandon
+		}
+		return nil
+	}, WithPipelinedDecode(func(msg *servicebus.Message) (interface{}, error) {
+		return msg.Data, nil
+	}), WithInvariantChecks())
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if want := []string{"a", "a", "b", "c"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	want := []Outcome{OutcomeAbandoned, OutcomeCompleted, OutcomeCompleted, OutcomeCompleted}
+	if got := outcomesOf(broker); !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+}
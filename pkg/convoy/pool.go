@@ -0,0 +1,71 @@
+package convoy
+
+import (
+	"context"
+)
+
+// WithConcurrentSessions processes up to n sessions at a time, each accepted by its own receive loop with its own
+// watchdog. Messages within a session are still handled one at a time in order; only different sessions progress in
+// parallel. If the namespace's quota does not permit n receivers, the convoy continues with as many as it could open,
+// see Summary.Concurrency.
+func WithConcurrentSessions(n int) Option {
+	return func(c *Convoy) {
+		c.concurrentSessions = n
+	}
+}
+
+// WithHandlerPoolSize runs handlers on a pool of n workers shared by all concurrent sessions, instead of on the
+// receive loop of their session. Many sessions can then be held at once while the work done on their messages is
+// bounded by the pool, which suits many sessions with light handlers. A session waits for its current message to be
+// handled before it hands over the next one, so no two workers ever run messages of the same session. Time a message
+// spends waiting for a worker counts toward the idle timeout, so the pool should be sized to keep waits well below it.
+func WithHandlerPoolSize(n int) Option {
+	return func(c *Convoy) {
+		c.poolSize = n
+	}
+}
+
+// handlerPool runs submitted handler invocations on a fixed number of workers
+type handlerPool struct {
+	work chan func()
+	quit chan struct{}
+}
+
+func newHandlerPool(size int) *handlerPool {
+	p := &handlerPool{
+		work: make(chan func()),
+		quit: make(chan struct{}),
+	}
+	for i := 0; i < size; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *handlerPool) worker() {
+	for {
+		select {
+		case fn := <-p.work:
+			fn()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// stop ends the workers once they finished their current work
+func (p *handlerPool) stop() {
+	close(p.quit)
+}
+
+// run invokes handler on a worker and waits for its result. It gives up waiting for a free worker when ctx is done.
+func (p *handlerPool) run(ctx context.Context, handler func() error) error {
+	result := make(chan error, 1)
+	select {
+	case p.work <- func() { result <- handler() }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return <-result
+}
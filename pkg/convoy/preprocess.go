@@ -0,0 +1,41 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// PreProcessFunc transforms a received message before it is decoded and handled, e.g. to decrypt or decompress its
+// body or to migrate it to the current schema. Unlike a DecodeFunc, which turns the body into a value for the handler,
+// it operates on the whole message, properties included, and its result replaces the message for the handler.
+type PreProcessFunc func(ctx context.Context, msg *servicebus.Message) (*servicebus.Message, error)
+
+// WithPreProcessor runs fn on every message before it is decoded and handled. The message the handler receives is
+// the one returned by fn, while settlement always applies to the received message. A message fn fails on is
+// dead-lettered with reason PreProcessFailed. fn must not have side effects since it may run before the previous
+// message of the session is settled.
+func WithPreProcessor(fn PreProcessFunc) Option {
+	return func(c *Convoy) {
+		c.preProcess = fn
+	}
+}
+
+// preProcess returns the message to hand to the handler
+func (sh *StepSessionHandler) preProcess(ctx context.Context, msg *servicebus.Message) (out *servicebus.Message, err error) {
+	if sh.convoy.preProcess == nil {
+		return msg, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			out, err = msg, fmt.Errorf("pre-processor panicked: %v", r)
+		}
+	}()
+	out, err = sh.convoy.preProcess(ctx, msg)
+	if err != nil || out == nil {
+		return msg, err
+	}
+	return out, nil
+}
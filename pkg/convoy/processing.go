@@ -0,0 +1,60 @@
+package convoy
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// WithProcessingLog logs a line when the convoy starts processing a message, before its handler runs, and one with the
+// outcome once the message is settled, both tagged with its correlation value. Every attempt then leaves a trace, even
+// one whose handler hangs, times out or takes down the process, in which case the start has no matching finish.
+func WithProcessingLog() Option {
+	return func(c *Convoy) {
+		c.processingLog = true
+	}
+}
+
+// processingStarted records that processing of msg, correlated through ctx, begins
+func (sh *StepSessionHandler) processingStarted(ctx context.Context, msg *servicebus.Message) {
+	if sh.convoy.processingLog {
+		sh.convoy.msgLogf(ctx, "▶ Processing message %s, delivery %d.", msg.ID, msg.DeliveryCount)
+	}
+	sh.convoy.emit(Event{
+		Kind:           EventProcessingStarted,
+		SessionID:      sessionIDOf(msg),
+		SequenceNumber: sequenceOf(msg),
+		CorrelationID:  CorrelationID(ctx),
+	})
+}
+
+// processingFinished records the outcome of processing msg, which took latency since it was received. err is the
+// error of the settlement, if it failed, or of the handler.
+func (sh *StepSessionHandler) processingFinished(ctx context.Context, msg *servicebus.Message, outcome Outcome, latency time.Duration, err error) {
+	if sh.convoy.processingLog {
+		if err != nil {
+			sh.convoy.msgLogf(ctx, "⏹ Finished processing message %s in %v: %s: %v", msg.ID, latency, outcome, err)
+		} else {
+			sh.convoy.msgLogf(ctx, "⏹ Finished processing message %s in %v: %s.", msg.ID, latency, outcome)
+		}
+	}
+
+	e := Event{
+		SessionID:      sessionIDOf(msg),
+		SequenceNumber: sequenceOf(msg),
+		CorrelationID:  CorrelationID(ctx),
+		Outcome:        outcome,
+		Latency:        latency,
+		Err:            err,
+	}
+	if kind, ok := messageEvent(outcome); ok || err != nil {
+		if err != nil {
+			kind = EventMessageFailed
+		}
+		e.Kind = kind
+		sh.convoy.emit(e)
+	}
+	e.Kind = EventProcessingFinished
+	sh.convoy.emit(e)
+}
@@ -0,0 +1,96 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// ErrProcessorStarted is returned by Start when the processor was already started
+var ErrProcessorStarted = errors.New("processor already started")
+
+// SessionHandler processes the messages of the sessions a Processor receives: one message at a time and in order
+// within a session. Like a HandlerFunc, it must not settle the message itself; the returned error selects the
+// settlement. HandlerFunc implements it.
+type SessionHandler interface {
+	HandleMessage(ctx context.Context, msg *servicebus.Message) error
+}
+
+// HandleMessage calls f
+func (f HandlerFunc) HandleMessage(ctx context.Context, msg *servicebus.Message) error {
+	return f(ctx, msg)
+}
+
+// Processor runs a convoy in the background for services that embed it, e.g. next to an HTTP server. The convoy is
+// embedded, so its methods such as Send, HealthHandler or Config are available on the processor.
+type Processor struct {
+	*Convoy
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	err     error
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// NewProcessor creates a processor handing the messages of the sessions on queue qName, or on the subscription set with
+// WithTopicSubscription, to handler. It accepts the options of New; handler may only be nil with WithStreamHandler.
+func NewProcessor(connStr, qName string, handler SessionHandler, opts ...Option) (*Processor, error) {
+	var fn HandlerFunc
+	if handler != nil {
+		fn = handler.HandleMessage
+	}
+	c, err := New(connStr, qName, fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Processor{Convoy: c, done: make(chan struct{})}, nil
+}
+
+// Start runs the convoy in the background until ctx is cancelled, Stop is called or the convoy stops on an error. A
+// processor is started once; Start returns ErrProcessorStarted on later calls.
+func (p *Processor) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return ErrProcessorStarted
+	}
+	p.started = true
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(p.done)
+		p.err = p.Run(ctx)
+	}()
+	return nil
+}
+
+// Done is closed once the convoy stopped, whether through Stop, the context of Start or an error
+func (p *Processor) Done() <-chan struct{} {
+	return p.done
+}
+
+// Stop stops the convoy as if the context of Start was cancelled, waits for the current sessions to drain and closes
+// the convoy. It returns the error that stopped the convoy, if any, or the error of closing it. Stop on a processor
+// that was never started only closes the convoy, and later calls return the result of the first.
+func (p *Processor) Stop() error {
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		started, cancel := p.started, p.cancel
+		p.started = true
+		p.mu.Unlock()
+
+		if started && cancel != nil {
+			cancel()
+			<-p.done
+		}
+		p.stopErr = firstErr(p.err, p.Close(context.Background()))
+	})
+	return p.stopErr
+}
@@ -0,0 +1,144 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// Reply completes the message after sending Body as a reply to the entity named in the ReplyTo of the message. Return
+// it from a handler with ReplyWith. Use errors.As to inspect it.
+type Reply struct {
+	Body []byte
+}
+
+// ReplyWith returns a Reply for the handler to return in place of nil. The reply carries the correlation ID of the
+// message, or its message ID if it has none, and is sent into the session named by the ReplyToGroupID of the message,
+// if any. A message without ReplyTo is settled according to the UnroutableReplyPolicy, by default dead-lettered. If the
+// reply cannot be sent the message is abandoned, so it is handled again rather than completed unanswered.
+func ReplyWith(body []byte) error {
+	return &Reply{Body: body}
+}
+
+// Error describes the reply
+func (r *Reply) Error() string {
+	return fmt.Sprintf("reply with %d bytes", len(r.Body))
+}
+
+// ErrNoReplyTo is the handler error reported under UnroutableReplyFail for a reply to a message without ReplyTo
+var ErrNoReplyTo = errors.New("message to reply to has no ReplyTo")
+
+// UnroutableReplyPolicy selects how a message is settled whose handler replied with ReplyWith although the message has
+// no ReplyTo, which usually means its producer is broken
+type UnroutableReplyPolicy int
+
+const (
+	// UnroutableReplyDeadLetter dead-letters the message with reason "no reply-to", surfacing the producer error
+	UnroutableReplyDeadLetter UnroutableReplyPolicy = iota
+	// UnroutableReplyComplete completes the message and drops the reply
+	UnroutableReplyComplete
+	// UnroutableReplyFail fails the handler with ErrNoReplyTo: the message is abandoned and the convoy stops, like
+	// for any other handler error
+	UnroutableReplyFail
+)
+
+// WithUnroutableReplyPolicy sets how a message without ReplyTo is settled when its handler replies to it. The default
+// is UnroutableReplyDeadLetter.
+func WithUnroutableReplyPolicy(policy UnroutableReplyPolicy) Option {
+	return func(c *Convoy) {
+		c.unroutableReply = policy
+	}
+}
+
+// unroutableReply applies the UnroutableReplyPolicy to a reply to a message without ReplyTo
+func (sh *StepSessionHandler) unroutableReply(ctx context.Context, msg *servicebus.Message, st settlement) settlement {
+	if st.reply == nil || msg.ReplyTo != "" {
+		return st
+	}
+
+	switch sh.convoy.unroutableReply {
+	case UnroutableReplyComplete:
+		return settlement{outcome: OutcomeCompleted}
+	case UnroutableReplyFail:
+		sh.convoy.msgLogf(ctx, "❗ Message %s has no ReplyTo to send the reply to.", msg.ID)
+		return settlementFor(ErrNoReplyTo)
+	default:
+		sh.convoy.msgLogf(ctx, "❗ Message %s has no ReplyTo to send the reply to. Dead-lettering it.", msg.ID)
+		return settlementFor(&ErrDeadLetter{Reason: "no reply-to", Description: "handler replied to a message without ReplyTo"})
+	}
+}
+
+// replySender sends replies to one entity, a *servicebus.Queue outside of tests
+type replySender interface {
+	Send(ctx context.Context, msg *servicebus.Message) error
+	Close(ctx context.Context) error
+}
+
+// replySenders holds a sender per reply entity, opened on first use
+type replySenders struct {
+	sync.Mutex
+	open    func(entity string) (replySender, error)
+	senders map[string]replySender
+}
+
+// sender returns the sender for entity, opening it if needed
+func (r *replySenders) sender(entity string) (replySender, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if q, ok := r.senders[entity]; ok {
+		return q, nil
+	}
+	q, err := r.open(entity)
+	if err != nil {
+		return nil, err
+	}
+	if r.senders == nil {
+		r.senders = make(map[string]replySender)
+	}
+	r.senders[entity] = q
+	return q, nil
+}
+
+// close closes all senders and returns the first error
+func (r *replySenders) close(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+
+	var first error
+	for entity, q := range r.senders {
+		if err := q.Close(ctx); err != nil && first == nil {
+			first = err
+		}
+		delete(r.senders, entity)
+	}
+	return first
+}
+
+// sendReply sends the reply of st for msg and returns the settlement to apply to msg
+func (sh *StepSessionHandler) sendReply(ctx context.Context, msg *servicebus.Message, st settlement) settlement {
+	reply := servicebus.NewMessage(st.reply.Body)
+	reply.CorrelationID = msg.CorrelationID
+	if reply.CorrelationID == "" {
+		reply.CorrelationID = msg.ID
+	}
+	if msg.ReplyToGroupID != "" {
+		sessionID := msg.ReplyToGroupID
+		reply.SessionID = &sessionID
+	}
+
+	q, err := sh.convoy.replies.sender(msg.ReplyTo)
+	if err == nil {
+		err = q.Send(ctx, reply)
+	}
+	if err != nil {
+		sh.convoy.msgLogf(ctx, "❗ Failed to send reply to %s, abandoning message: %v", msg.ReplyTo, err)
+		return settlement{outcome: OutcomeAbandoned}
+	}
+
+	sh.convoy.msgLogf(ctx, "↩ Sent reply to %s.", msg.ReplyTo)
+	return st
+}
@@ -0,0 +1,158 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// fakeReplies records the replies sent per entity, failing the sends for which sendErr returns an error
+type fakeReplies struct {
+	mu      sync.Mutex
+	sent    map[string][]*servicebus.Message
+	sendErr func(reply *servicebus.Message) error
+}
+
+// fakeReplySender sends the replies to one entity of a fakeReplies
+type fakeReplySender struct {
+	replies *fakeReplies
+	entity  string
+}
+
+func (s fakeReplySender) Send(_ context.Context, reply *servicebus.Message) error {
+	r := s.replies
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sendErr != nil {
+		if err := r.sendErr(reply); err != nil {
+			return err
+		}
+	}
+	r.sent[s.entity] = append(r.sent[s.entity], reply)
+	return nil
+}
+
+func (fakeReplySender) Close(context.Context) error { return nil }
+
+// newReplyConvoy returns a convoy over broker whose handler replies "ack" to every message, sending the replies to
+// the returned fakeReplies
+func newReplyConvoy(t *testing.T, broker *fakeBroker, opts ...Option) (*Convoy, *fakeReplies) {
+	replies := &fakeReplies{sent: make(map[string][]*servicebus.Message)}
+	c := newTestConvoy(t, broker, func(context.Context, *servicebus.Message) error {
+		return ReplyWith([]byte("ack"))
+	}, opts...)
+	c.replies.open = func(entity string) (replySender, error) {
+		return fakeReplySender{replies: replies, entity: entity}, nil
+	}
+	return c, replies
+}
+
+// outcomesOf returns the outcomes of the settlements on broker, in order
+func outcomesOf(broker *fakeBroker) []Outcome {
+	var outcomes []Outcome
+	for _, s := range broker.settlements() {
+		outcomes = append(outcomes, s.outcome)
+	}
+	return outcomes
+}
+
+func TestReplyCorrelatesWithMessage(t *testing.T) {
+	broker := newFakeBroker()
+	msgs := broker.add("a", "1", "2")
+	msgs[0].ReplyTo = "responses"
+	msgs[0].CorrelationID = "request-1"
+	msgs[0].ReplyToGroupID = "caller"
+	msgs[1].ReplyTo = "responses"
+	c, replies := newReplyConvoy(t, broker)
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	sent := replies.sent["responses"]
+	if len(sent) != 2 {
+		t.Fatalf("sent %d replies to responses, want 2", len(sent))
+	}
+	if sent[0].CorrelationID != "request-1" || sent[0].SessionID == nil || *sent[0].SessionID != "caller" {
+		t.Errorf("first reply has correlation ID %q and session %v, want request-1 and caller", sent[0].CorrelationID, sent[0].SessionID)
+	}
+	// Without a correlation ID the reply correlates with the message ID
+	if sent[1].CorrelationID != msgs[1].ID || sent[1].SessionID != nil {
+		t.Errorf("second reply has correlation ID %q and session %v, want %q and none", sent[1].CorrelationID, sent[1].SessionID, msgs[1].ID)
+	}
+	if got, want := outcomesOf(broker), []Outcome{OutcomeCompleted, OutcomeCompleted}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+}
+
+func TestReplyAbandonsMessageWhenSendFails(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1")[0].ReplyTo = "responses"
+	c, replies := newReplyConvoy(t, broker)
+	failed := false
+	replies.sendErr = func(*servicebus.Message) error {
+		if failed {
+			return nil
+		}
+		failed = true
+		return errors.New("entity not found")
+	}
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if got, want := outcomesOf(broker), []Outcome{OutcomeAbandoned, OutcomeCompleted}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+	if n := len(replies.sent["responses"]); n != 1 {
+		t.Errorf("sent %d replies, want 1", n)
+	}
+}
+
+func TestUnroutableReplyPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		outcome Outcome
+		reason  string
+		wantErr error
+	}{
+		{name: "default dead-letters", outcome: OutcomeDeadLettered, reason: "no reply-to"},
+		{
+			name:    "dead-letter",
+			opts:    []Option{WithUnroutableReplyPolicy(UnroutableReplyDeadLetter)},
+			outcome: OutcomeDeadLettered,
+			reason:  "no reply-to",
+		},
+		{name: "complete", opts: []Option{WithUnroutableReplyPolicy(UnroutableReplyComplete)}, outcome: OutcomeCompleted},
+		{
+			name:    "fail",
+			opts:    []Option{WithUnroutableReplyPolicy(UnroutableReplyFail)},
+			outcome: OutcomeAbandoned,
+			wantErr: ErrNoReplyTo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker()
+			broker.add("a", "1")
+			c, replies := newReplyConvoy(t, broker, tt.opts...)
+
+			_, err := c.RunOnce(context.Background())
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RunOnce = %v, want %v", err, tt.wantErr)
+			}
+			settled := broker.settlements()
+			if len(settled) != 1 || settled[0].outcome != tt.outcome || settled[0].reason != tt.reason {
+				t.Errorf("settlements %+v, want one %s with reason %q", settled, tt.outcome, tt.reason)
+			}
+			if len(replies.sent) != 0 {
+				t.Errorf("sent replies %v to a message without ReplyTo", replies.sent)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package convoy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RunReport summarizes a run of the convoy for a post-mortem of the process. The message counters count the same
+// settlements as the convoy_messages_processed_total metric, broken down by outcome, and Expiries counts the same
+// expiries as convoy_session_expiries_total.
+type RunReport struct {
+	Summary
+
+	Completed    int64
+	Abandoned    int64
+	DeadLettered int64
+	Deferred     int64
+
+	// Expiries counts the sessions closed by the watchdog and Reconnects the reconnections after connection failures
+	Expiries   int64
+	Reconnects int64
+
+	// Err is the error Run or RunOnce returned
+	Err error
+}
+
+// WithRunReport calls fn with a report of the run whenever Run or RunOnce returns, and logs the report
+func WithRunReport(fn func(RunReport)) Option {
+	return func(c *Convoy) {
+		c.onReport = fn
+	}
+}
+
+// addOutcome counts a settled message by its outcome
+func (s *runStats) addOutcome(o Outcome) {
+	switch o {
+	case OutcomeCompleted:
+		atomic.AddInt64(&s.completed, 1)
+	case OutcomeAbandoned:
+		atomic.AddInt64(&s.abandoned, 1)
+	case OutcomeDeadLettered:
+		atomic.AddInt64(&s.deadLettered, 1)
+	case OutcomeDeferred:
+		atomic.AddInt64(&s.deferred, 1)
+	}
+}
+
+func (s *runStats) addExpiry() {
+	atomic.AddInt64(&s.expiries, 1)
+}
+
+func (s *runStats) addReconnect() {
+	atomic.AddInt64(&s.reconnects, 1)
+}
+
+// report returns the report of the run that ended with err
+func (s *runStats) report(err error) RunReport {
+	return RunReport{
+		Summary:      s.summary(),
+		Completed:    atomic.LoadInt64(&s.completed),
+		Abandoned:    atomic.LoadInt64(&s.abandoned),
+		DeadLettered: atomic.LoadInt64(&s.deadLettered),
+		Deferred:     atomic.LoadInt64(&s.deferred),
+		Expiries:     atomic.LoadInt64(&s.expiries),
+		Reconnects:   atomic.LoadInt64(&s.reconnects),
+		Err:          err,
+	}
+}
+
+// reportRun logs the report of the run and passes it to the callback set with WithRunReport
+func (c *Convoy) reportRun(stats *runStats, err error) {
+	if c.onReport == nil {
+		return
+	}
+
+	r := stats.report(err)
+	c.logf("📊 Run report: %d sessions, %d messages (%d completed, %d abandoned, %d dead-lettered, %d deferred), %d expiries, %d reconnects in %v.",
+		r.Sessions, r.Messages, r.Completed, r.Abandoned, r.DeadLettered, r.Deferred, r.Expiries, r.Reconnects, r.Duration.Round(time.Second))
+	c.onReport(r)
+}
@@ -0,0 +1,113 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// retryCountProperty is the application property counting how often a message went through the retry queue
+const retryCountProperty = "ConvoyRetryCount"
+
+// WithRetryQueue adds a second tier of retries for messages that fail on their last delivery, which would otherwise
+// be dead-lettered by the broker, e.g. because an outage outlasted all deliveries. Such a message is instead sent to
+// queue, scheduled to become visible after delay, and the original is completed. The delay doubles with every pass
+// through the retry queue, and once a message has passed through it maxRetries times it is dead-lettered as before.
+//
+// The retry queue is an entity to create alongside the convoy's queue. It must auto-forward (ForwardTo) to the convoy's
+// queue, or to the topic for a convoy on a topic subscription since a subscription cannot be a forwarding target, so
+// that a retried message re-enters its session once its delay has passed, and it must be session enabled if the
+// convoy requires sessions. A message forwarded to the topic reaches every subscription whose rules match it; retried
+// messages carry the ConvoyRetryCount property, so the rules of other subscriptions can exclude them. Run warns if the
+// retry queue does not forward to the right entity. A retried message keeps its session ID but joins the end of its
+// session, so messages sent after it are processed before it. Messages the handler dead-letters explicitly never take
+// this path.
+func WithRetryQueue(queue string, maxRetries int, delay time.Duration) Option {
+	return func(c *Convoy) {
+		c.retry = &retryQueue{name: queue, maxRetries: maxRetries, delay: delay}
+	}
+}
+
+// retryQueue is the second retry tier
+type retryQueue struct {
+	name       string
+	maxRetries int
+	delay      time.Duration
+	sender     *servicebus.Queue
+}
+
+// validate checks the retry queue settings
+func (r *retryQueue) validate() error {
+	if r.name == "" || r.maxRetries < 1 || r.delay <= 0 {
+		return errors.New("retry queue needs a name, at least one retry and a positive delay")
+	}
+	return nil
+}
+
+// forwardTarget returns the entity the retry queue must forward to: the topic of a subscription or else the queue
+func (c *Convoy) forwardTarget() string {
+	if c.topicName != "" {
+		return c.topicName
+	}
+	return c.queueName
+}
+
+// checkRetryForward warns if the retry queue does not auto-forward to the forward target, in which case retried
+// messages never return to their session
+func (c *Convoy) checkRetryForward(ctx context.Context) {
+	if c.retry == nil || c.namespace == nil {
+		return
+	}
+
+	target := c.forwardTarget()
+	qe, err := c.namespace.NewQueueManager().Get(ctx, c.retry.name)
+	switch {
+	case err != nil:
+		c.logf("❗ Could not determine whether retry queue %s forwards to %s: %v", c.retry.name, target, err)
+	case !forwardsTo(qe.ForwardTo, target):
+		c.logf("❗ Retry queue %s does not forward to %s. Retried messages will not return to their session.", c.retry.name, target)
+	}
+}
+
+// forwardsTo reports whether forwardTo, the ForwardTo of a queue, names entity. Service Bus reports it as a URI or a
+// plain entity name, and entity names are case insensitive.
+func forwardsTo(forwardTo *string, entity string) bool {
+	if forwardTo == nil || entity == "" {
+		return false
+	}
+	fwd := strings.ToLower(strings.TrimSuffix(*forwardTo, "/"))
+	entity = strings.ToLower(entity)
+	return fwd == entity || strings.HasSuffix(fwd, "/"+entity)
+}
+
+// retryLater moves a message that failed on its last delivery to the retry queue while its retry budget lasts. It
+// returns st unchanged if the message is not on its last delivery, its budget is exhausted or it cannot be sent.
+func (sh *StepSessionHandler) retryLater(ctx context.Context, msg *servicebus.Message, st settlement) settlement {
+	r := sh.convoy.retry
+	if r == nil || st.outcome != OutcomeAbandoned || st.release || msg.DeliveryCount < sh.convoy.currentMaxDeliveryCount() {
+		return st
+	}
+
+	retries, _ := msg.UserProperties[retryCountProperty].(int64)
+	if int(retries) >= r.maxRetries {
+		sh.convoy.msgLogf(ctx, "❗ Message failed after %d passes through the retry queue. Giving up.", retries)
+		return st
+	}
+
+	delay := r.delay << uint(retries)
+	cp := copyMessage(msg)
+	cp.SessionID = msg.SessionID
+	cp.UserProperties[retryCountProperty] = retries + 1
+	sh.convoy.stampWorker(cp.UserProperties)
+	cp.ScheduleAt(time.Now().Add(delay))
+	if err := r.sender.Send(ctx, cp); err != nil {
+		sh.convoy.msgLogf(ctx, "❗ Failed to move message to retry queue %s: %v", r.name, err)
+		return st
+	}
+
+	sh.convoy.msgLogf(ctx, "⏲ Message failed on its last delivery. Retrying it through %s in %v (retry %d of %d).", r.name, delay, retries+1, r.maxRetries)
+	return settlement{outcome: OutcomeCompleted}
+}
@@ -0,0 +1,43 @@
+package convoy
+
+import "testing"
+
+func TestForwardsTo(t *testing.T) {
+	uri := func(s string) *string { return &s }
+	tests := []struct {
+		forwardTo *string
+		entity    string
+		want      bool
+	}{
+		{forwardTo: uri("https://example.servicebus.windows.net/orders"), entity: "orders", want: true},
+		{forwardTo: uri("sb://example.servicebus.windows.net/orders/"), entity: "orders", want: true},
+		{forwardTo: uri("https://example.servicebus.windows.net/Orders"), entity: "orders", want: true},
+		{forwardTo: uri("orders"), entity: "orders", want: true},
+		{forwardTo: uri("https://example.servicebus.windows.net/orders-retry"), entity: "orders", want: false},
+		{forwardTo: uri("https://example.servicebus.windows.net/backorders"), entity: "orders", want: false},
+		{forwardTo: uri("https://example.servicebus.windows.net/orders"), entity: "", want: false},
+		{forwardTo: nil, entity: "orders", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := forwardsTo(tt.forwardTo, tt.entity); got != tt.want {
+			fwd := "<nil>"
+			if tt.forwardTo != nil {
+				fwd = *tt.forwardTo
+			}
+			t.Errorf("forwardsTo(%q, %q) = %v, want %v", fwd, tt.entity, got, tt.want)
+		}
+	}
+}
+
+func TestForwardTarget(t *testing.T) {
+	queue := &Convoy{queueName: "orders"}
+	if got := queue.forwardTarget(); got != "orders" {
+		t.Errorf("forward target of a queue = %q, want orders", got)
+	}
+
+	sub := &Convoy{queueName: "events/Subscriptions/billing", topicName: "events", subscriptionName: "billing"}
+	if got := sub.forwardTarget(); got != "events" {
+		t.Errorf("forward target of a subscription = %q, want the topic events", got)
+	}
+}
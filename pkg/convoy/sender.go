@@ -0,0 +1,271 @@
+package convoy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// ErrMissingSessionID is returned when a message is sent without a session ID
+var ErrMissingSessionID = errors.New("session ID must not be empty")
+
+// ErrSharedMessageID is returned by SendAll when messages of the batch with different bodies would share a message ID,
+// as they do with WithMessageID. Duplicate detection would drop all but the first of them. Use WithMessageIDFunc to
+// give each message its own ID.
+var ErrSharedMessageID = errors.New("messages of a batch share a message ID, use WithMessageIDFunc")
+
+// Retry policy of Send, SendScheduled and SendAll unless set with WithSendRetryPolicy
+const (
+	defaultSendAttempts = 3
+	defaultSendBackoff  = 500 * time.Millisecond
+)
+
+// WithSendRetryPolicy retries sends that fail with a transient error, i.e. a timeout, throttling or a dropped
+// connection or link, up to attempts times in total, doubling backoff after each failure. Other errors, such as a
+// message exceeding the maximum size, fail right away. A send that failed ambiguously may still have reached the
+// queue, so a retry can enqueue the message twice: sends are at least once. Set a message ID with WithMessageID on a
+// queue with duplicate detection to have the broker drop such duplicates.
+func WithSendRetryPolicy(attempts int, backoff time.Duration) Option {
+	return func(c *Convoy) {
+		c.sendAttempts = attempts
+		c.sendBackoff = backoff
+	}
+}
+
+// SendOption configures a message before it is sent
+type SendOption func(*servicebus.Message) error
+
+// WithMessageID sets the message ID. On queues with duplicate detection enabled the broker drops a message whose ID
+// was already sent within the detection window, which makes retried sends idempotent. On other queues the ID is only
+// informational. SendAll rejects it with ErrSharedMessageID since it would give every message the same ID.
+func WithMessageID(id string) SendOption {
+	return func(msg *servicebus.Message) error {
+		msg.ID = id
+		return nil
+	}
+}
+
+// WithMessageIDFunc derives the message ID from the body, e.g. a hash or a business key, so that each message sent by
+// SendAll gets its own ID
+func WithMessageIDFunc(fn func(body []byte) string) SendOption {
+	return func(msg *servicebus.Message) error {
+		msg.ID = fn(msg.Data)
+		return nil
+	}
+}
+
+// WithProperties sets application properties on the message
+func WithProperties(props map[string]interface{}) SendOption {
+	return func(msg *servicebus.Message) error {
+		if msg.UserProperties == nil {
+			msg.UserProperties = make(map[string]interface{}, len(props))
+		}
+		for k, v := range props {
+			msg.UserProperties[k] = v
+		}
+		return nil
+	}
+}
+
+// WithSessionIDFunc derives the session ID of each message from its body, e.g. an order ID in the payload, in place of
+// the session ID passed to Send or SendAll, which may then be empty. With SendAll this fans a batch out across
+// sessions, keeping the order of the batch within each session. An error of fn, or an empty ID, fails the send.
+func WithSessionIDFunc(fn func(body []byte) (string, error)) SendOption {
+	return func(msg *servicebus.Message) error {
+		id, err := fn(msg.Data)
+		if err != nil {
+			return fmt.Errorf("derive session ID: %w", err)
+		}
+		msg.SessionID = &id
+		return nil
+	}
+}
+
+// Send enqueues body as the next message of session sessionID on the convoy's queue
+func (c *Convoy) Send(ctx context.Context, sessionID string, body []byte, opts ...SendOption) error {
+	return c.send(ctx, sessionID, body, nil, opts)
+}
+
+// SendScheduled enqueues body in session sessionID so that it becomes visible at at, e.g. to run the next step of a
+// convoy after a retry-after window. Messages of a session are handed out in sequence number order, which is assigned
+// when the message is sent, but a scheduled message is only delivered once its time has come. Until then the session
+// continues with the messages that are already visible, so scheduling delays when a step runs rather than holding back
+// the steps sent after it.
+func (c *Convoy) SendScheduled(ctx context.Context, sessionID string, body []byte, at time.Time, opts ...SendOption) error {
+	return c.send(ctx, sessionID, body, &at, opts)
+}
+
+// SendStatus is the state of a message of a SendAll batch
+type SendStatus int
+
+const (
+	// SendPending means the message was not attempted since an earlier message of the batch failed
+	SendPending SendStatus = iota
+	// SendSucceeded means the message was enqueued
+	SendSucceeded
+	// SendFailed means sending the message failed. It may still have reached the queue if the failure was ambiguous,
+	// e.g. a timeout.
+	SendFailed
+)
+
+// BatchResult reports the status of every message of a SendAll batch, indexed like the bodies passed to SendAll
+type BatchResult struct {
+	SessionID string
+	Statuses  []SendStatus
+
+	bodies [][]byte
+	opts   []SendOption
+}
+
+// Sent returns the number of messages that were enqueued. Since a batch stops at its first failure, they are the
+// first Sent messages of the batch.
+func (r *BatchResult) Sent() int {
+	n := 0
+	for n < len(r.Statuses) && r.Statuses[n] == SendSucceeded {
+		n++
+	}
+	return n
+}
+
+// Complete reports whether all messages of the batch were enqueued
+func (r *BatchResult) Complete() bool {
+	return r.Sent() == len(r.Statuses)
+}
+
+// SendAll enqueues bodies in order as consecutive messages of session sessionID. It stops at the first failure and
+// reports the status of every message in the result, so a caller can tell which messages were sent and resume the
+// batch with ResumeSendAll rather than sending it again.
+func (c *Convoy) SendAll(ctx context.Context, sessionID string, bodies [][]byte, opts ...SendOption) (*BatchResult, error) {
+	r := &BatchResult{
+		SessionID: sessionID,
+		Statuses:  make([]SendStatus, len(bodies)),
+		bodies:    bodies,
+		opts:      opts,
+	}
+	return r, c.sendBatch(ctx, r, 0)
+}
+
+// ResumeSendAll sends the messages of r from the first one that was not enqueued, in their original order and into
+// the same session, and updates r. The message that failed is sent again, so if its failure was ambiguous it may be
+// enqueued twice; set a message ID with WithMessageIDFunc on a queue with duplicate detection to have the broker drop
+// the duplicate.
+func (c *Convoy) ResumeSendAll(ctx context.Context, r *BatchResult) (*BatchResult, error) {
+	return r, c.sendBatch(ctx, r, r.Sent())
+}
+
+// sendBatch sends the messages of r starting at index from. All messages are built before the first is sent, so a
+// batch that cannot be built, e.g. since its messages share an ID, fails without sending any of them.
+func (c *Convoy) sendBatch(ctx context.Context, r *BatchResult, from int) error {
+	for i := from; i < len(r.bodies); i++ {
+		r.Statuses[i] = SendPending
+	}
+
+	msgs := make([]*servicebus.Message, len(r.bodies))
+	explicit := make([]bool, len(r.bodies))
+	bodyOf := make(map[string][]byte)
+	for i := from; i < len(r.bodies); i++ {
+		msg, explicitID, err := newMessage(r.SessionID, r.bodies[i], nil, r.opts)
+		if err == nil && explicitID {
+			if body, ok := bodyOf[msg.ID]; ok && !bytes.Equal(body, msg.Data) {
+				err = ErrSharedMessageID
+			}
+			bodyOf[msg.ID] = msg.Data
+		}
+		if err != nil {
+			r.Statuses[i] = SendFailed
+			return fmt.Errorf("send message %d of %d: %w", i+1, len(r.bodies), err)
+		}
+		msgs[i], explicit[i] = msg, explicitID
+	}
+
+	for i := from; i < len(r.bodies); i++ {
+		if err := c.sendMessage(ctx, msgs[i], explicit[i]); err != nil {
+			r.Statuses[i] = SendFailed
+			return fmt.Errorf("send message %d of %d: %w", i+1, len(r.bodies), err)
+		}
+		r.Statuses[i] = SendSucceeded
+	}
+
+	return nil
+}
+
+func (c *Convoy) send(ctx context.Context, sessionID string, body []byte, at *time.Time, opts []SendOption) error {
+	msg, explicitID, err := newMessage(sessionID, body, at, opts)
+	if err != nil {
+		return err
+	}
+	return c.sendMessage(ctx, msg, explicitID)
+}
+
+// newMessage builds the message of body in session sessionID and reports whether its ID was set by an option
+func newMessage(sessionID string, body []byte, at *time.Time, opts []SendOption) (*servicebus.Message, bool, error) {
+	msg := servicebus.NewMessage(body)
+	msg.SessionID = &sessionID
+	if at != nil {
+		msg.ScheduleAt(*at)
+	}
+	generatedID := msg.ID
+	for _, opt := range opts {
+		if err := opt(msg); err != nil {
+			return nil, false, err
+		}
+	}
+	if *msg.SessionID == "" {
+		return nil, false, ErrMissingSessionID
+	}
+	return msg, msg.ID != generatedID, nil
+}
+
+// sendMessage sends msg, retrying transient failures. explicitID reports whether the ID of msg was set by the sender.
+func (c *Convoy) sendMessage(ctx context.Context, msg *servicebus.Message, explicitID bool) error {
+	if explicitID {
+		c.checkDuplicateDetection(ctx)
+	}
+
+	backoff := c.sendBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.source.send(ctx, msg)
+		if err == nil || attempt >= c.sendAttempts || !c.isTransient(err) {
+			return err
+		}
+
+		if c.classify(err) == ErrorThrottle {
+			err = c.throttled(ctx, err)
+		} else {
+			c.logf("❗ Failed to send message (attempt %d of %d), retrying in %v: %v", attempt, c.sendAttempts, backoff, err)
+			err = sleepCtx(ctx, backoff)
+		}
+		if err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// isTransient reports whether a send that failed with err may succeed when retried
+func (c *Convoy) isTransient(err error) bool {
+	switch c.classify(err) {
+	case ErrorTimeout, ErrorThrottle, ErrorConnection, ErrorLockLost:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkDuplicateDetection warns once if the queue does not detect duplicates, in which case message IDs set by the
+// sender do not prevent duplicates
+func (c *Convoy) checkDuplicateDetection(ctx context.Context) {
+	c.dedupCheck.Do(func() {
+		qe, err := c.source.describe(ctx)
+		switch {
+		case err != nil:
+			c.logf("❗ Could not determine whether the queue detects duplicates: %v", err)
+		case qe.RequiresDuplicateDetection == nil || !*qe.RequiresDuplicateDetection:
+			c.logf("❗ Duplicate detection is not enabled on the queue. Message IDs will not prevent duplicates.")
+		}
+	})
+}
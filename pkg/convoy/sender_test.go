@@ -0,0 +1,206 @@
+package convoy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-amqp"
+)
+
+func TestSendAllRejectsSharedMessageID(t *testing.T) {
+	broker := newFakeBroker()
+	c := newTestConvoy(t, broker, nopHandler)
+	bodies := [][]byte{[]byte("1"), []byte("2"), []byte("3")}
+
+	r, err := c.SendAll(context.Background(), "a", bodies, WithMessageID("order-1"))
+	if !errors.Is(err, ErrSharedMessageID) {
+		t.Fatalf("SendAll with WithMessageID = %v, want %v", err, ErrSharedMessageID)
+	}
+	if len(broker.sent) != 0 {
+		t.Errorf("sent %d messages of a rejected batch", len(broker.sent))
+	}
+	if want := []SendStatus{SendPending, SendFailed, SendPending}; !reflect.DeepEqual(r.Statuses, want) {
+		t.Errorf("statuses %v, want %v", r.Statuses, want)
+	}
+
+	if _, err := c.SendAll(context.Background(), "a", bodies, WithMessageIDFunc(func(body []byte) string {
+		return "order-" + string(body)
+	})); err != nil {
+		t.Fatalf("SendAll with WithMessageIDFunc: %v", err)
+	}
+	var ids []string
+	for _, msg := range broker.sent {
+		ids = append(ids, msg.ID)
+	}
+	if want := []string{"order-1", "order-2", "order-3"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sent IDs %v, want %v", ids, want)
+	}
+}
+
+func TestSendAllAcceptsMessageIDOfSingleMessage(t *testing.T) {
+	broker := newFakeBroker()
+	c := newTestConvoy(t, broker, nopHandler)
+
+	if _, err := c.SendAll(context.Background(), "a", [][]byte{[]byte("1")}, WithMessageID("order-1")); err != nil {
+		t.Fatalf("SendAll: %v", err)
+	}
+	if len(broker.sent) != 1 || broker.sent[0].ID != "order-1" {
+		t.Errorf("sent %v, want one message with ID order-1", broker.sent)
+	}
+}
+
+// sentBodies returns the bodies of the messages sent to broker
+func sentBodies(broker *fakeBroker) []string {
+	var bodies []string
+	for _, msg := range broker.sent {
+		bodies = append(bodies, string(msg.Data))
+	}
+	return bodies
+}
+
+func TestSendAllReportsPartialFailure(t *testing.T) {
+	broker := newFakeBroker()
+	failure := errors.New("message too large")
+	failing := true
+	broker.sendErr = func(msg *servicebus.Message) error {
+		if failing && string(msg.Data) == "3" {
+			return failure
+		}
+		return nil
+	}
+	c := newTestConvoy(t, broker, nopHandler)
+	bodies := [][]byte{[]byte("1"), []byte("2"), []byte("3"), []byte("4")}
+
+	r, err := c.SendAll(context.Background(), "a", bodies)
+	if !errors.Is(err, failure) {
+		t.Fatalf("SendAll = %v, want %v", err, failure)
+	}
+	if want := []SendStatus{SendSucceeded, SendSucceeded, SendFailed, SendPending}; !reflect.DeepEqual(r.Statuses, want) {
+		t.Errorf("statuses %v, want %v", r.Statuses, want)
+	}
+	if r.Sent() != 2 || r.Complete() {
+		t.Errorf("Sent() = %d, Complete() = %v, want 2 and false", r.Sent(), r.Complete())
+	}
+
+	// Resuming sends the failed message and the rest, and only those
+	failing = false
+	if _, err := c.ResumeSendAll(context.Background(), r); err != nil {
+		t.Fatalf("ResumeSendAll: %v", err)
+	}
+	if !r.Complete() || r.Sent() != 4 {
+		t.Errorf("after resume Sent() = %d, Complete() = %v, want 4 and true", r.Sent(), r.Complete())
+	}
+	if got, want := sentBodies(broker), []string{"1", "2", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+	for _, msg := range broker.sent {
+		if *msg.SessionID != "a" {
+			t.Errorf("message %s sent to session %s, want a", msg.Data, *msg.SessionID)
+		}
+	}
+}
+
+func TestSendAllOfEmptyBatch(t *testing.T) {
+	c := newTestConvoy(t, newFakeBroker(), nopHandler)
+
+	r, err := c.SendAll(context.Background(), "a", nil)
+	if err != nil || !r.Complete() || r.Sent() != 0 {
+		t.Errorf("SendAll of no bodies = %+v, %v, want a complete empty batch", r, err)
+	}
+}
+
+// orderIDOf extracts the session ID from bodies of the form <order>:<step>
+func orderIDOf(body []byte) (string, error) {
+	i := bytes.IndexByte(body, ':')
+	if i < 0 {
+		return "", errors.New("no order ID")
+	}
+	return string(body[:i]), nil
+}
+
+func TestSendAllFansOutBySessionIDFunc(t *testing.T) {
+	broker := newFakeBroker()
+	c := newTestConvoy(t, broker, nopHandler)
+	bodies := [][]byte{[]byte("o1:pay"), []byte("o2:pay"), []byte("o1:ship"), []byte(":none"), []byte("o2:ship")}
+
+	// An empty ID derived from a body fails the send, and the batch stops there
+	r, err := c.SendAll(context.Background(), "", bodies, WithSessionIDFunc(orderIDOf))
+	if !errors.Is(err, ErrMissingSessionID) {
+		t.Fatalf("SendAll = %v, want %v", err, ErrMissingSessionID)
+	}
+	if want := []SendStatus{SendPending, SendPending, SendPending, SendFailed, SendPending}; !reflect.DeepEqual(r.Statuses, want) {
+		t.Errorf("statuses %v, want %v", r.Statuses, want)
+	}
+
+	bodies = append(bodies[:3], bodies[4])
+	if _, err := c.SendAll(context.Background(), "", bodies, WithSessionIDFunc(orderIDOf)); err != nil {
+		t.Fatalf("SendAll: %v", err)
+	}
+	perSession := map[string][]string{}
+	for _, msg := range broker.sent {
+		perSession[*msg.SessionID] = append(perSession[*msg.SessionID], string(msg.Data))
+	}
+	want := map[string][]string{"o1": {"o1:pay", "o1:ship"}, "o2": {"o2:pay", "o2:ship"}}
+	if !reflect.DeepEqual(perSession, want) {
+		t.Errorf("sent %v, want %v", perSession, want)
+	}
+}
+
+func TestSendSessionIDFuncError(t *testing.T) {
+	broker := newFakeBroker()
+	c := newTestConvoy(t, broker, nopHandler)
+
+	err := c.Send(context.Background(), "fallback", []byte("no separator"), WithSessionIDFunc(orderIDOf))
+	if err == nil || !strings.Contains(err.Error(), "derive session ID") {
+		t.Errorf("Send = %v, want the error of the session ID function", err)
+	}
+	if len(broker.sent) != 0 {
+		t.Errorf("sent %d messages without a session ID", len(broker.sent))
+	}
+}
+
+func TestSendRetriesTransientFailure(t *testing.T) {
+	broker := newFakeBroker()
+	attempts := 0
+	broker.sendErr = func(*servicebus.Message) error {
+		if attempts++; attempts < 3 {
+			return &amqp.Error{Condition: conditionTimeout, Description: "send timed out"}
+		}
+		return nil
+	}
+	c := newTestConvoy(t, broker, nopHandler, WithSendRetryPolicy(3, time.Millisecond))
+
+	if err := c.Send(context.Background(), "a", []byte("1")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+	if got, want := sentBodies(broker), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %v, want %v", got, want)
+	}
+}
+
+func TestSendDoesNotRetryPermanentFailure(t *testing.T) {
+	broker := newFakeBroker()
+	failure := errors.New("message too large")
+	attempts := 0
+	broker.sendErr = func(*servicebus.Message) error {
+		attempts++
+		return failure
+	}
+	c := newTestConvoy(t, broker, nopHandler, WithSendRetryPolicy(3, time.Millisecond))
+
+	if err := c.Send(context.Background(), "a", []byte("1")); !errors.Is(err, failure) {
+		t.Fatalf("Send = %v, want %v", err, failure)
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts, want 1", attempts)
+	}
+}
@@ -0,0 +1,122 @@
+package convoy
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Settings is a snapshot of the effective configuration of a convoy, including values resolved at run time such as
+// the lock duration of the queue
+type Settings struct {
+	QueueName           string        `json:"queue_name,omitempty"`
+	TopicName           string        `json:"topic_name,omitempty"`
+	SubscriptionName    string        `json:"subscription_name,omitempty"`
+	WorkerID            string        `json:"worker_id,omitempty"`
+	IdleTimeout         time.Duration `json:"idle_timeout"`
+	HandlerTimeout      time.Duration `json:"handler_timeout"`
+	LockDuration        time.Duration `json:"lock_duration"`
+	WatchdogInterval    time.Duration `json:"watchdog_interval"`
+	HardShutdownTimeout time.Duration `json:"hard_shutdown_timeout"`
+	SettleAttempts      int           `json:"settle_attempts"`
+	SettleBackoff       time.Duration `json:"settle_backoff"`
+	EmptyAccepts        int           `json:"empty_accepts"`
+	ShardIndex          int           `json:"shard_index"`
+	ShardTotal          int           `json:"shard_total"`
+	ReceiveStrategy     string        `json:"receive_strategy"`
+	ConcurrentSessions  int           `json:"concurrent_sessions"`
+	AutoScaleMin        int           `json:"auto_scale_min,omitempty"`
+	AutoScaleMax        int           `json:"auto_scale_max,omitempty"`
+	HandlerPoolSize     int           `json:"handler_pool_size,omitempty"`
+	MaxInFlightBytes    int64         `json:"max_in_flight_bytes,omitempty"`
+	PipelinedDecode     bool          `json:"pipelined_decode"`
+	WebSocket           bool          `json:"web_socket"`
+	DeadLetterForward   string        `json:"dead_letter_forward,omitempty"`
+}
+
+// MarshalJSON encodes the durations in their readable form, e.g. 1m30s
+func (s Settings) MarshalJSON() ([]byte, error) {
+	type plain Settings
+	return json.Marshal(struct {
+		plain
+		IdleTimeout         string `json:"idle_timeout"`
+		HandlerTimeout      string `json:"handler_timeout"`
+		LockDuration        string `json:"lock_duration"`
+		WatchdogInterval    string `json:"watchdog_interval"`
+		HardShutdownTimeout string `json:"hard_shutdown_timeout"`
+		SettleBackoff       string `json:"settle_backoff"`
+	}{
+		plain:               plain(s),
+		IdleTimeout:         s.IdleTimeout.String(),
+		HandlerTimeout:      s.HandlerTimeout.String(),
+		LockDuration:        s.LockDuration.String(),
+		WatchdogInterval:    s.WatchdogInterval.String(),
+		HardShutdownTimeout: s.HardShutdownTimeout.String(),
+		SettleBackoff:       s.SettleBackoff.String(),
+	})
+}
+
+// Config returns the current configuration of the convoy. It is safe to call while the convoy is running.
+func (c *Convoy) Config() Settings {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+
+	s := Settings{
+		QueueName:           c.queueName,
+		TopicName:           c.topicName,
+		SubscriptionName:    c.subscriptionName,
+		WorkerID:            c.workerID,
+		IdleTimeout:         c.idleTimeout,
+		HandlerTimeout:      c.handlerTimeout,
+		LockDuration:        c.lockDuration,
+		WatchdogInterval:    c.watchdogInterval,
+		HardShutdownTimeout: c.hardShutdownTimeout,
+		SettleAttempts:      c.settleAttempts,
+		SettleBackoff:       c.settleBackoff,
+		EmptyAccepts:        c.emptyAccepts,
+		ShardIndex:          c.shardIndex,
+		ShardTotal:          c.shardTotal,
+		ReceiveStrategy:     c.strategy.String(),
+		ConcurrentSessions:  c.concurrentSessions,
+		HandlerPoolSize:     c.poolSize,
+		PipelinedDecode:     c.decode != nil,
+		WebSocket:           c.webSocket,
+	}
+	if c.topicName != "" {
+		// The queue name holds the entity path of the subscription
+		s.QueueName = ""
+	}
+	if s.ConcurrentSessions < 1 {
+		s.ConcurrentSessions = 1
+	}
+	if c.autoScale != nil {
+		s.AutoScaleMin = int(c.autoScale.min)
+		s.AutoScaleMax = int(atomic.LoadInt64(&c.autoScale.max))
+	}
+	if c.inFlight != nil {
+		s.MaxInFlightBytes = c.inFlight.max
+	}
+	if c.forward != nil {
+		s.DeadLetterForward = c.forward.target
+	}
+
+	return s
+}
+
+// HealthHandler serves the health of the convoy as JSON: whether it is running, whether it sheds load under memory
+// pressure and its current configuration. It responds with 503 Service Unavailable while the convoy is not running.
+func (c *Convoy) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		running := atomic.LoadInt32(&c.running) == 1
+		w.Header().Set("Content-Type", "application/json")
+		if !running {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(struct {
+			Running  bool     `json:"running"`
+			Shedding bool     `json:"shedding"`
+			Config   Settings `json:"config"`
+		}{running, c.isShedding(), c.Config()})
+	})
+}
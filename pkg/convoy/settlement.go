@@ -0,0 +1,110 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// Sentinel errors a HandlerFunc returns to choose how its message is settled. Returning nil completes the message, and
+// so does returning ReplyWith after sending its reply.
+// Context errors are classified as well: context.DeadlineExceeded, typically from the handler timeout, abandons the
+// message like ErrAbandon so the attempt counts toward the queue's maximum delivery count, and context.Canceled, from
+// a shutdown, leaves the message unsettled and releases the session so the message is attempted afresh after a
+// restart without being treated as a failure. Any other error abandons the message and stops the convoy.
+var (
+	// ErrAbandon abandons the message. The broker redelivers it as the next message of the same session, so ordering
+	// is preserved and the delivery count is incremented.
+	ErrAbandon = errors.New("abandon message")
+
+	// ErrDefer defers the message. It is set aside and the session moves on to the next message, so the deferred
+	// message leaves the convoy order and has to be received explicitly by its sequence number.
+	ErrDefer = errors.New("defer message")
+
+	// ErrRetryLater abandons the message and releases the session. Ordering is preserved: the message is the first
+	// one handled when the session is accepted again, by this or any other receiver.
+	ErrRetryLater = errors.New("retry message later")
+)
+
+// errLockLost cancels a handler whose session lock could not be renewed. Its message can no longer be settled, so it
+// is left to the broker, which redelivers it in order once the session is accepted again.
+var errLockLost = errors.New("session lock lost")
+
+// ErrDeadLetter moves the message to the dead-letter queue with the given reason and description. The session moves
+// on to the next message, so the dead-lettered message is skipped in the convoy order. Use errors.As to inspect it.
+type ErrDeadLetter struct {
+	Reason      string
+	Description string
+}
+
+// Error returns the dead-letter reason and description
+func (e *ErrDeadLetter) Error() string {
+	return fmt.Sprintf("dead-letter message: %s: %s", e.Reason, e.Description)
+}
+
+// settlement is the action derived from the error returned by a handler
+type settlement struct {
+	outcome    Outcome
+	deadLetter *ErrDeadLetter
+	release    bool
+	err        error
+
+	// Application properties set on a dead-lettered message, e.g. the worker identity
+	properties map[string]interface{}
+
+	// Reply sent before the message is completed, see ReplyWith
+	reply *Reply
+}
+
+// settlementFor maps the handler result to the settlement of its message
+func settlementFor(err error) settlement {
+	var dl *ErrDeadLetter
+	var reply *Reply
+	switch {
+	case err == nil:
+		return settlement{outcome: OutcomeCompleted}
+	case errors.As(err, &reply):
+		return settlement{outcome: OutcomeCompleted, reply: reply}
+	case errors.As(err, &dl):
+		return settlement{outcome: OutcomeDeadLettered, deadLetter: dl}
+	case errors.Is(err, ErrAbandon):
+		return settlement{outcome: OutcomeAbandoned}
+	case errors.Is(err, ErrDefer):
+		return settlement{outcome: OutcomeDeferred}
+	case errors.Is(err, ErrRetryLater):
+		return settlement{outcome: OutcomeAbandoned, release: true}
+	case errors.Is(err, context.DeadlineExceeded):
+		return settlement{outcome: OutcomeAbandoned}
+	case errors.Is(err, errLockLost):
+		return settlement{outcome: OutcomeReleased, release: true}
+	case errors.Is(err, context.Canceled):
+		return settlement{outcome: OutcomeReleased, release: true}
+	default:
+		return settlement{outcome: OutcomeAbandoned, err: err}
+	}
+}
+
+// apply settles the message with the broker
+func (s settlement) apply(ctx context.Context, msg *servicebus.Message) error {
+	switch s.outcome {
+	case OutcomeReleased:
+		return nil
+	case OutcomeCompleted:
+		return msg.Complete(ctx)
+	case OutcomeDeferred:
+		return msg.Defer(ctx)
+	case OutcomeDeadLettered:
+		info := map[string]string{
+			"DeadLetterReason":           s.deadLetter.Reason,
+			"DeadLetterErrorDescription": s.deadLetter.Description,
+		}
+		for k, v := range s.properties {
+			info[k] = fmt.Sprint(v)
+		}
+		return msg.DeadLetterWithInfo(ctx, s.deadLetter, servicebus.ErrorInternalError, info)
+	default:
+		return msg.Abandon(ctx)
+	}
+}
@@ -0,0 +1,203 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+	"github.com/Azure/go-amqp"
+)
+
+func TestSettlementFor(t *testing.T) {
+	failure := errors.New("boom")
+	dl := &ErrDeadLetter{Reason: "Invalid", Description: "missing order"}
+	reply := &Reply{Body: []byte("ok")}
+
+	tests := []struct {
+		name string
+		err  error
+		want settlement
+	}{
+		{name: "nil", err: nil, want: settlement{outcome: OutcomeCompleted}},
+		{name: "abandon", err: ErrAbandon, want: settlement{outcome: OutcomeAbandoned}},
+		{name: "defer", err: ErrDefer, want: settlement{outcome: OutcomeDeferred}},
+		{name: "retry later", err: ErrRetryLater, want: settlement{outcome: OutcomeAbandoned, release: true}},
+		{name: "dead-letter", err: dl, want: settlement{outcome: OutcomeDeadLettered, deadLetter: dl}},
+		{name: "reply", err: reply, want: settlement{outcome: OutcomeCompleted, reply: reply}},
+		{name: "wrapped abandon", err: fmt.Errorf("step 2: %w", ErrAbandon), want: settlement{outcome: OutcomeAbandoned}},
+		{name: "wrapped defer", err: fmt.Errorf("step 2: %w", ErrDefer), want: settlement{outcome: OutcomeDeferred}},
+		{name: "wrapped retry later", err: fmt.Errorf("step 2: %w", ErrRetryLater), want: settlement{outcome: OutcomeAbandoned, release: true}},
+		{name: "wrapped dead-letter", err: fmt.Errorf("step 2: %w", dl), want: settlement{outcome: OutcomeDeadLettered, deadLetter: dl}},
+		{name: "wrapped reply", err: fmt.Errorf("step 2: %w", reply), want: settlement{outcome: OutcomeCompleted, reply: reply}},
+		{name: "deadline exceeded", err: context.DeadlineExceeded, want: settlement{outcome: OutcomeAbandoned}},
+		{name: "canceled", err: fmt.Errorf("handler: %w", context.Canceled), want: settlement{outcome: OutcomeReleased, release: true}},
+		{name: "lock lost", err: errLockLost, want: settlement{outcome: OutcomeReleased, release: true}},
+		{name: "other error", err: failure, want: settlement{outcome: OutcomeAbandoned, err: failure}},
+		{name: "wrapped other error", err: fmt.Errorf("step 2: %w", failure), want: settlement{outcome: OutcomeAbandoned, err: fmt.Errorf("step 2: %w", failure)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := settlementFor(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("settlementFor(%v) = %+v, want %+v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// failSettleOnce makes broker fail the first settlement attempt of message id with err and returns the number of
+// attempts made for it
+func failSettleOnce(broker *fakeBroker, id string, err error) func() int {
+	var mu sync.Mutex
+	attempts := 0
+	broker.settleErr = func(msg *servicebus.Message) error {
+		if msg.ID != id {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			return err
+		}
+		return nil
+	}
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return attempts
+	}
+}
+
+func TestSettleRetriesTransientFailure(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	attempts := failSettleOnce(broker, "a-1", errors.New("operation timed out"))
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	}, WithSettleRetry(3, time.Millisecond))
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if n := attempts(); n != 2 {
+		t.Errorf("%d settlement attempts for a-1, want 2", n)
+	}
+	// The retry settles the message without handling it again
+	if want := []string{"a-1", "a-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if got, want := outcomesOf(broker), []Outcome{OutcomeCompleted, OutcomeCompleted}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outcomes %v, want %v", got, want)
+	}
+	if summary.Sessions != 1 {
+		t.Errorf("%d sessions, want 1", summary.Sessions)
+	}
+}
+
+func TestSettleLockLostReleasesSession(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	attempts := failSettleOnce(broker, "a-1", &amqp.Error{Condition: conditionMessageLockLost, Description: "lock lost"})
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	}, WithSettleRetry(3, time.Millisecond))
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// A lost lock is not retried: the session is released and the message redelivered in order on the next accept
+	if n := attempts(); n != 2 {
+		t.Errorf("%d settlement attempts for a-1, want one per delivery", n)
+	}
+	if want := []string{"a-1", "a-1", "a-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if summary.Sessions != 2 {
+		t.Errorf("%d sessions, want the session accepted again after its release", summary.Sessions)
+	}
+	if n := broker.remaining(); n != 0 {
+		t.Errorf("%d messages left on the broker", n)
+	}
+}
+
+func TestHandleDeadLettersMessageWithoutSessionID(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	var handled []string
+	h := newSyncHarness(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	})
+
+	msg, _ := broker.next("a")
+	msg.SessionID = nil
+	if err := h.sess.Handle(context.Background(), msg); err != nil {
+		t.Fatalf("Handle of message without session ID: %v", err)
+	}
+	if err := h.step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	if want := []string{"a-2"}; !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	settled := broker.settlements()
+	if len(settled) != 2 || settled[0].outcome != OutcomeDeadLettered || settled[0].reason != "missing session id" {
+		t.Errorf("settlements %+v, want a-1 dead-lettered with reason missing session id", settled)
+	}
+}
+
+func TestSettleOnSessionClosedMidHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		release bool
+		err     error
+	}{
+		{name: "closed by the convoy", release: true, err: errors.New("session closed")},
+		{name: "closed by the SDK", err: amqp.ErrSessionClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeBroker()
+			broker.add("a", "1", "2")
+			failSettleOnce(broker, "a-1", tt.err)
+			var h *syncHarness
+			h = newSyncHarness(t, broker, func(context.Context, *servicebus.Message) error {
+				if tt.release {
+					// As the watchdog or the hold time limit would while the handler runs
+					h.sess.release()
+				}
+				return nil
+			})
+
+			if err := h.step(); err != nil {
+				t.Fatalf("Handle of message whose session closed: %v", err)
+			}
+			if !h.sess.isReleased() || !h.ms.isClosed() {
+				t.Error("session not released after its settlement failed")
+			}
+			if n := len(broker.settlements()); n != 0 {
+				t.Errorf("%d messages settled, want none", n)
+			}
+
+			// The broker redelivers the message in order on the next accept
+			broker.unlock("a")
+			if msg, ok := broker.next("a"); !ok || msg.ID != "a-1" || msg.DeliveryCount != 2 {
+				t.Errorf("next delivery %+v, want a-1 delivered again", msg)
+			}
+		})
+	}
+}
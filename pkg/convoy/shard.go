@@ -0,0 +1,29 @@
+package convoy
+
+import (
+	"hash/fnv"
+)
+
+// The broker cannot hand out sessions filtered by a hash of their ID, so sharding is enforced on the client: the
+// convoy accepts any available session, hashes the session ID of its first message and, when the session belongs to
+// another shard, releases it without settling the message. The broker then offers the session to the next receiver
+// until the instance owning its shard accepts it. Every session ID maps to exactly one shard, so instances configured
+// with the same total and distinct indexes own disjoint sets of sessions that together cover all session IDs.
+//
+// Releasing a session frees its lock but the message received from it still counts as a delivery attempt, so
+// deployments with many shards should raise the queue's maximum delivery count accordingly.
+
+// shardOf returns the shard in [0, total) that owns sessionID
+func shardOf(sessionID string, total int) int {
+	h := fnv.New32a()
+	h.Write([]byte(sessionID))
+	return int(h.Sum32() % uint32(total))
+}
+
+// ownsSession reports whether sessionID belongs to the shard of this convoy
+func (c *Convoy) ownsSession(sessionID string) bool {
+	if c.shardTotal <= 1 {
+		return true
+	}
+	return shardOf(sessionID, c.shardTotal) == c.shardIndex
+}
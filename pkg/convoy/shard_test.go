@@ -0,0 +1,79 @@
+package convoy
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestShardOfIsStable(t *testing.T) {
+	// Instances of different versions must agree on the owner of a session, so the assignment must never change
+	tests := []struct {
+		sessionID string
+		total     int
+		want      int
+	}{
+		{sessionID: "", total: 4, want: 1},
+		{sessionID: "a", total: 4, want: 0},
+		{sessionID: "order-1", total: 4, want: 1},
+		{sessionID: "order-2", total: 4, want: 0},
+		{sessionID: "customer-42", total: 4, want: 2},
+		{sessionID: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", total: 4, want: 0},
+		{sessionID: "", total: 7, want: 2},
+		{sessionID: "a", total: 7, want: 5},
+		{sessionID: "order-1", total: 7, want: 1},
+		{sessionID: "customer-42", total: 7, want: 0},
+		{sessionID: "order-1", total: 1, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := shardOf(tt.sessionID, tt.total); got != tt.want {
+			t.Errorf("shardOf(%q, %d) = %d, want %d", tt.sessionID, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestShardOfSpreadsEvenly(t *testing.T) {
+	const sessions = 80000
+	for _, total := range []int{2, 3, 8, 16} {
+		counts := make([]int, total)
+		for i := 0; i < sessions; i++ {
+			shard := shardOf("session-"+strconv.Itoa(i), total)
+			if shard < 0 || shard >= total {
+				t.Fatalf("shardOf returned %d for %d shards", shard, total)
+			}
+			counts[shard]++
+		}
+
+		mean := sessions / total
+		for shard, n := range counts {
+			if n < mean*9/10 || n > mean*11/10 {
+				t.Errorf("shard %d of %d owns %d sessions, want within 10%% of %d", shard, total, n, mean)
+			}
+		}
+	}
+}
+
+func TestShardsOwnEverySessionOnce(t *testing.T) {
+	const total = 5
+	shards := make([]*Convoy, total)
+	for i := range shards {
+		shards[i] = &Convoy{shardIndex: i, shardTotal: total}
+	}
+
+	for i := 0; i < 1000; i++ {
+		id := "session-" + strconv.Itoa(i)
+		owners := 0
+		for _, c := range shards {
+			if c.ownsSession(id) {
+				owners++
+			}
+		}
+		if owners != 1 {
+			t.Fatalf("session %s owned by %d shards, want 1", id, owners)
+		}
+	}
+
+	if unsharded := (&Convoy{}); !unsharded.ownsSession("session-1") {
+		t.Error("convoy without shards does not own a session")
+	}
+}
@@ -0,0 +1,99 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Interval at which the memory probe is polled while the convoy sheds load
+const memoryCheckInterval = time.Second
+
+// metricShedding is 1 while the memory guard holds back new sessions and 0 otherwise
+const metricShedding = "convoy_shedding"
+
+// WithMemoryGuard stops accepting sessions while probe reports memory pressure at or above highWater and resumes once
+// it falls to lowWater or below, so a burst of sessions cannot drive the process into an OOM kill. Sessions that are
+// already accepted are finished as usual. The probe is consulted before every accept and should be cheap; use
+// RuntimeMemoryProbe for the Go heap or supply a probe of the cgroup usage. Whether the convoy is shedding is reported
+// as the convoy_shedding gauge and by HealthHandler.
+func WithMemoryGuard(probe func() float64, highWater, lowWater float64) Option {
+	return func(c *Convoy) {
+		c.memoryGuard = &memoryGuard{probe: probe, high: highWater, low: lowWater}
+	}
+}
+
+// RuntimeMemoryProbe returns a probe for WithMemoryGuard reporting the memory obtained from the OS by the Go runtime as
+// a fraction of limit bytes. Reading the memory statistics stops the world briefly, so the probe caches its result
+// for memoryCheckInterval.
+func RuntimeMemoryProbe(limit uint64) func() float64 {
+	var mu sync.Mutex
+	var last float64
+	var readAt time.Time
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if time.Since(readAt) >= memoryCheckInterval {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			last = float64(stats.Sys-stats.HeapReleased) / float64(limit)
+			readAt = time.Now()
+		}
+		return last
+	}
+}
+
+// memoryGuard applies hysteresis around the memory probe
+type memoryGuard struct {
+	probe     func() float64
+	high, low float64
+	shedding  int32
+}
+
+// validate checks the memory guard settings
+func (g *memoryGuard) validate() error {
+	if g.probe == nil || g.low > g.high {
+		return errors.New("memory guard needs a probe and a low water mark not above the high water mark")
+	}
+	return nil
+}
+
+// isShedding reports whether the memory guard currently holds back new sessions
+func (c *Convoy) isShedding() bool {
+	return c.memoryGuard != nil && atomic.LoadInt32(&c.memoryGuard.shedding) == 1
+}
+
+// awaitMemory returns once memory pressure permits accepting another session, or when ctx is done
+func (c *Convoy) awaitMemory(ctx context.Context) error {
+	g := c.memoryGuard
+	if g == nil {
+		return nil
+	}
+
+	for {
+		usage := g.probe()
+		switch {
+		case atomic.LoadInt32(&g.shedding) == 0 && usage >= g.high:
+			if atomic.CompareAndSwapInt32(&g.shedding, 0, 1) {
+				c.logf("🐘 Memory pressure at %.2f. Not accepting sessions until it falls to %.2f.", usage, g.low)
+				c.metrics.SetGauge(metricShedding, 1)
+			}
+		case atomic.LoadInt32(&g.shedding) == 1 && usage <= g.low:
+			if atomic.CompareAndSwapInt32(&g.shedding, 1, 0) {
+				c.logf("🐘 Memory pressure down to %.2f. Accepting sessions again.", usage)
+				c.metrics.SetGauge(metricShedding, 0)
+			}
+		}
+		if atomic.LoadInt32(&g.shedding) == 0 {
+			return nil
+		}
+
+		if err := sleepCtx(ctx, memoryCheckInterval); err != nil {
+			return err
+		}
+	}
+}
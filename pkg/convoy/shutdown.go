@@ -0,0 +1,38 @@
+package convoy
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext carries the values of its parent but not its cancellation or deadline, so that the handling of a
+// message outlives the shutdown signal of Run
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// drain stops the session from taking further messages: it is released right away if no handler is running, and
+// otherwise once the message of the running handler is settled
+func (sh *StepSessionHandler) drain() {
+	sh.Lock()
+	sh.draining = true
+	sh.Unlock()
+	sh.stopAfterCurrent()
+}
+
+// stopping reports whether the session is to be released after the message in flight, because the convoy is draining
+// it on shutdown or reached its maximum run duration
+func (sh *StepSessionHandler) stopping() bool {
+	sh.RLock()
+	draining := sh.draining
+	sh.RUnlock()
+	return draining || sh.convoy.isStopping()
+}
@@ -0,0 +1,148 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// runUntilStarted runs c until the handler signalled started, then cancels the context of Run and returns the channel
+// the result of Run is sent to
+func runUntilStarted(t *testing.T, c *Convoy, started <-chan struct{}) <-chan error {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Run(ctx)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not start")
+	}
+	cancel()
+	return errc
+}
+
+func TestRunDrainsMessageInFlightOnCancel(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		broker := newFakeBroker()
+		broker.add("a", "1", "2")
+		started, finish := make(chan struct{}), make(chan struct{})
+		var handlerErr error
+		c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+			close(started)
+			<-finish
+			handlerErr = ctx.Err()
+			return handlerErr
+		}, WithHardShutdownTimeout(timeout))
+
+		errc := runUntilStarted(t, c, started)
+		// The drain waits for the handler, however long it takes short of the hard shutdown timeout
+		time.Sleep(20 * time.Millisecond)
+		close(finish)
+		if err := <-errc; err != nil {
+			t.Fatalf("Run with hard shutdown timeout %v = %v, want nil", timeout, err)
+		}
+		if handlerErr != nil {
+			t.Errorf("handler context done with %v during the drain", handlerErr)
+		}
+		settled := broker.settlements()
+		if len(settled) != 1 || settled[0].messageID != "a-1" || settled[0].outcome != OutcomeCompleted {
+			t.Errorf("settlements %+v, want only a-1 completed", settled)
+		}
+		if n := broker.remaining(); n != 1 {
+			t.Errorf("%d messages left on the broker, want the one after the message in flight", n)
+		}
+	}
+}
+
+func TestRunHardShutdownCancelsHandler(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1")
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+		close(started)
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return ctx.Err()
+	}, WithHardShutdownTimeout(50*time.Millisecond))
+
+	begin := time.Now()
+	errc := runUntilStarted(t, c, started)
+	if err := <-errc; !errors.Is(err, ErrHardShutdown) {
+		t.Fatalf("Run = %v, want %v", err, ErrHardShutdown)
+	}
+	if elapsed := time.Since(begin); elapsed < 50*time.Millisecond {
+		t.Errorf("Run returned after %v, before the hard shutdown timeout", elapsed)
+	}
+	select {
+	case err := <-cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("handler context done with %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler not cancelled by the hard shutdown")
+	}
+
+	for _, s := range broker.settlements() {
+		if s.outcome == OutcomeCompleted {
+			t.Errorf("message %s completed after the hard shutdown", s.messageID)
+		}
+	}
+}
+
+func TestRunReturnsNilWhenCancelled(t *testing.T) {
+	t.Run("waiting for a session", func(t *testing.T) {
+		c := newTestConvoy(t, newFakeBroker(), nopHandler)
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() {
+			errc <- c.Run(ctx)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-errc:
+			if err != nil {
+				t.Errorf("Run = %v, want nil", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Run did not return after its context was cancelled")
+		}
+	})
+
+	t.Run("before it started", func(t *testing.T) {
+		broker := newFakeBroker()
+		broker.add("a", "1")
+		c := newTestConvoy(t, broker, nopHandler)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := c.Run(ctx); err != nil {
+			t.Errorf("Run = %v, want nil", err)
+		}
+	})
+}
+
+func TestDetachedContextKeepsValues(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+	cancel()
+
+	ctx := detachedContext{parent: parent}
+	if ctx.Err() != nil || ctx.Done() != nil {
+		t.Errorf("detached context done with %v", ctx.Err())
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("detached context has a deadline")
+	}
+	if v := ctx.Value(key{}); v != "v" {
+		t.Errorf("value %v, want v", v)
+	}
+}
@@ -0,0 +1,69 @@
+package convoy
+
+import (
+	"context"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// defaultMaxDeliveryCount is the maximum delivery count Service Bus assigns to new queues
+const defaultMaxDeliveryCount = 10
+
+// WithSkipOnDeadLetter keeps the convoy running when a handler fails with an error other than the settlement
+// sentinels. The message is abandoned and retried as the head of its session until its last delivery, on which it is
+// dead-lettered with the handler's error as description, and the session continues with its next message. Without this
+// option such an error stops the convoy so that no message of the session is processed out of order. With it, the
+// failed message is skipped: the messages after it are processed in order, but without the effect of the skipped one,
+// which has to be repaired from the dead-letter queue.
+func WithSkipOnDeadLetter() Option {
+	return func(c *Convoy) {
+		c.skipOnDeadLetter = true
+	}
+}
+
+// resolveMaxDeliveryCount determines the maximum delivery count of the queue, falling back to the Service Bus default
+// if the management API cannot be queried
+func (c *Convoy) resolveMaxDeliveryCount(ctx context.Context) {
+	if (!c.skipOnDeadLetter && c.retry == nil) || c.currentMaxDeliveryCount() > 0 {
+		return
+	}
+
+	n := uint32(defaultMaxDeliveryCount)
+	defer func() {
+		c.settingsMu.Lock()
+		c.maxDeliveryCount = n
+		c.settingsMu.Unlock()
+	}()
+
+	qe, err := c.source.describe(ctx)
+	if err != nil {
+		c.logf("❗ Failed to query maximum delivery count of queue, assuming %d: %v", n, err)
+		return
+	}
+	if qe.MaxDeliveryCount != nil && *qe.MaxDeliveryCount > 0 {
+		n = uint32(*qe.MaxDeliveryCount)
+	}
+}
+
+// currentMaxDeliveryCount returns the maximum delivery count in thread safe manner
+func (c *Convoy) currentMaxDeliveryCount() uint32 {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.maxDeliveryCount
+}
+
+// skipFailed turns a handler failure into a retry and, on the last delivery of the message, into a dead-letter, so
+// the session continues instead of the convoy stopping
+func (sh *StepSessionHandler) skipFailed(ctx context.Context, msg *servicebus.Message, st settlement) settlement {
+	if !sh.convoy.skipOnDeadLetter || st.err == nil {
+		return st
+	}
+
+	if maxDeliveries := sh.convoy.currentMaxDeliveryCount(); msg.DeliveryCount < maxDeliveries {
+		sh.convoy.msgLogf(ctx, "❗ Handler failed on delivery %d of %d, retrying message: %v", msg.DeliveryCount, maxDeliveries, st.err)
+		return settlement{outcome: OutcomeAbandoned}
+	}
+
+	sh.convoy.msgLogf(ctx, "❗ Handler failed on last delivery. Dead-lettering message and continuing with session: %v", st.err)
+	return settlementFor(&ErrDeadLetter{Reason: "HandlerFailed", Description: st.err.Error()})
+}
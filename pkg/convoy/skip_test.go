@@ -0,0 +1,90 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestResolveMaxDeliveryCountConcurrently(t *testing.T) {
+	broker := newFakeBroker()
+	maxDeliveries := int32(3)
+	broker.description.MaxDeliveryCount = &maxDeliveries
+	c := newTestConvoy(t, broker, nopHandler, WithSkipOnDeadLetter())
+
+	// Run and DrainSession resolve the maximum delivery count while sessions read it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.resolveMaxDeliveryCount(context.Background())
+		}()
+		go func() {
+			defer wg.Done()
+			c.currentMaxDeliveryCount()
+		}()
+	}
+	wg.Wait()
+
+	if got := c.currentMaxDeliveryCount(); got != 3 {
+		t.Errorf("maximum delivery count = %d, want 3", got)
+	}
+}
+
+func TestDrainSessionResolvesMaxDeliveryCount(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1")
+	c := newTestConvoy(t, broker, nopHandler, WithSkipOnDeadLetter())
+
+	if _, err := c.DrainSession(context.Background(), "a", nopHandler); err != nil {
+		t.Fatalf("DrainSession: %v", err)
+	}
+	if got := c.currentMaxDeliveryCount(); got != defaultMaxDeliveryCount {
+		t.Errorf("maximum delivery count = %d outside of Run, want the default %d", got, defaultMaxDeliveryCount)
+	}
+}
+
+func TestSkipOnDeadLetterContinuesSessionInOrder(t *testing.T) {
+	for _, drain := range []bool{false, true} {
+		broker := newFakeBroker()
+		broker.add("a", "1", "2", "3")
+		maxDeliveries := int32(3)
+		broker.description.MaxDeliveryCount = &maxDeliveries
+
+		var handled []string
+		handler := func(_ context.Context, msg *servicebus.Message) error {
+			handled = append(handled, string(msg.Data))
+			if string(msg.Data) == "2" {
+				return errors.New("step rejected")
+			}
+			return nil
+		}
+		c := newTestConvoy(t, broker, handler, WithSkipOnDeadLetter())
+
+		var err error
+		if drain {
+			_, err = c.DrainSession(context.Background(), "a", handler)
+		} else {
+			_, err = c.RunOnce(context.Background())
+		}
+		if err != nil {
+			t.Fatalf("drain %v: %v", drain, err)
+		}
+
+		if want := []string{"1", "2", "2", "2", "3"}; !reflect.DeepEqual(handled, want) {
+			t.Errorf("drain %v: handled %v, want %v", drain, handled, want)
+		}
+		want := []Outcome{OutcomeCompleted, OutcomeAbandoned, OutcomeAbandoned, OutcomeDeadLettered, OutcomeCompleted}
+		if got := outcomesOf(broker); !reflect.DeepEqual(got, want) {
+			t.Errorf("drain %v: outcomes %v, want %v", drain, got, want)
+		}
+		if settled := broker.settlements(); len(settled) == len(want) && settled[3].reason != "HandlerFailed" {
+			t.Errorf("drain %v: dead-letter reason %q, want HandlerFailed", drain, settled[3].reason)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package convoy
+
+import (
+	"context"
+	"time"
+)
+
+// sleepCtx waits for d unless ctx is done first, in which case it returns the context's error right away. A zero or
+// negative d returns immediately, reporting cancellation only if ctx is already done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
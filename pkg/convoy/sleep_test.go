@@ -0,0 +1,47 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSleepCtxReturnsEarlyOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := sleepCtx(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("sleepCtx = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("sleepCtx returned after %v, want right after the cancellation", elapsed)
+	}
+}
+
+func TestSleepCtx(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		d    time.Duration
+		want error
+	}{
+		{name: "elapsed", ctx: context.Background(), d: time.Millisecond, want: nil},
+		{name: "zero", ctx: context.Background(), d: 0, want: nil},
+		{name: "negative", ctx: context.Background(), d: -time.Second, want: nil},
+		{name: "already cancelled", ctx: cancelled, d: time.Minute, want: context.Canceled},
+		{name: "zero on cancelled", ctx: cancelled, d: 0, want: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sleepCtx(tt.ctx, tt.d); !errors.Is(err, tt.want) {
+				t.Errorf("sleepCtx(%v) = %v, want %v", tt.d, err, tt.want)
+			}
+		})
+	}
+}
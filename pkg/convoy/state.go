@@ -0,0 +1,88 @@
+package convoy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// ErrNoSession is returned when session state is accessed outside of a handler
+var ErrNoSession = errors.New("no session in context")
+
+type sessionKey struct{}
+
+// StateCodec converts the state of a session between a Go value and the bytes stored with the session
+type StateCodec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec stores session state as JSON
+type JSONCodec[T any] struct{}
+
+// Marshal encodes v as JSON
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into a T
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// SessionState reads and writes the typed state of the session handled with a context. Handlers use it to checkpoint
+// the progress of a workflow, so that a message redelivered after a failure, possibly to another receiver, resumes
+// from the last step that completed.
+type SessionState[T any] struct {
+	codec StateCodec[T]
+}
+
+// NewSessionState creates a SessionState that uses codec, or JSON if codec is nil
+func NewSessionState[T any](codec StateCodec[T]) *SessionState[T] {
+	if codec == nil {
+		codec = JSONCodec[T]{}
+	}
+	return &SessionState[T]{codec: codec}
+}
+
+// Get returns the state of the session handled with ctx. A session without state, e.g. on its first message, yields
+// the zero value of T.
+func (s *SessionState[T]) Get(ctx context.Context) (T, error) {
+	var zero T
+	ms, ok := ctx.Value(sessionKey{}).(lockedSession)
+	if !ok || ms == nil {
+		return zero, ErrNoSession
+	}
+
+	data, err := ms.State(ctx)
+	if err != nil || len(data) == 0 {
+		return zero, err
+	}
+	return s.codec.Unmarshal(data)
+}
+
+// Set replaces the state of the session handled with ctx
+func (s *SessionState[T]) Set(ctx context.Context, v T) error {
+	ms, ok := ctx.Value(sessionKey{}).(lockedSession)
+	if !ok || ms == nil {
+		return ErrNoSession
+	}
+
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ms.SetState(ctx, data)
+}
+
+// GetState returns the JSON encoded state of the session handled with ctx, see SessionState.Get
+func GetState[T any](ctx context.Context) (T, error) {
+	return NewSessionState[T](nil).Get(ctx)
+}
+
+// SetState stores v as the JSON encoded state of the session handled with ctx
+func SetState[T any](ctx context.Context, v T) error {
+	return NewSessionState[T](nil).Set(ctx, v)
+}
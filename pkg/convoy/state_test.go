@@ -0,0 +1,64 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+type workflowState struct {
+	Step  int
+	Steps []string
+}
+
+func TestSessionStateRoundTrip(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "reserve", "charge", "ship")
+
+	state := NewSessionState[workflowState](nil)
+	var seen []workflowState
+	c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+		v, err := state.Get(ctx)
+		if err != nil {
+			return err
+		}
+		seen = append(seen, v)
+		v.Step++
+		v.Steps = append(v.Steps, string(msg.Data))
+		return state.Set(ctx, v)
+	}, WithMaxMessagesPerSessionVisit(1))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	// Every message is handled in a visit of its own, so the state has to survive the session being released.
+	want := []workflowState{
+		{},
+		{Step: 1, Steps: []string{"reserve"}},
+		{Step: 2, Steps: []string{"reserve", "charge"}},
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("handlers saw state %+v, want %+v", seen, want)
+	}
+
+	final, err := JSONCodec[workflowState]{}.Unmarshal(broker.states["a"])
+	if err != nil {
+		t.Fatalf("decoding stored state: %v", err)
+	}
+	if want := (workflowState{Step: 3, Steps: []string{"reserve", "charge", "ship"}}); !reflect.DeepEqual(final, want) {
+		t.Errorf("stored state %+v, want %+v", final, want)
+	}
+}
+
+func TestSessionStateOutsideHandler(t *testing.T) {
+	if _, err := GetState[workflowState](context.Background()); !errors.Is(err, ErrNoSession) {
+		t.Errorf("GetState: got %v, want %v", err, ErrNoSession)
+	}
+	if err := SetState(context.Background(), workflowState{Step: 1}); !errors.Is(err, ErrNoSession) {
+		t.Errorf("SetState: got %v, want %v", err, ErrNoSession)
+	}
+}
@@ -0,0 +1,93 @@
+package convoy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Summary describes the work done by a call to RunOnce
+type Summary struct {
+	Sessions int64
+	Messages int64
+	Duration time.Duration
+
+	// Concurrency is the number of sessions the convoy could process at a time, which is below the configured number
+	// of concurrent sessions if the namespace's quota did not permit more receivers
+	Concurrency int64
+
+	// HoldTime is the total time sessions were held, from their accept to their release, and MaxHoldTime the longest
+	// a single session was held. The time of each session is also observed as convoy_session_duration_seconds.
+	HoldTime    time.Duration
+	MaxHoldTime time.Duration
+}
+
+// runStats counts the work done during a run. Counters are updated atomically since sessions report from the
+// receiver goroutines.
+type runStats struct {
+	sessions int64
+	messages int64
+	loops    int64
+	start    time.Time
+
+	// Counters of the run report
+	completed    int64
+	abandoned    int64
+	deadLettered int64
+	deferred     int64
+	expiries     int64
+	reconnects   int64
+
+	// Nanoseconds sessions were held in total and at most
+	holdTime    int64
+	maxHoldTime int64
+}
+
+func (s *runStats) addSession() {
+	atomic.AddInt64(&s.sessions, 1)
+}
+
+func (s *runStats) addMessage() {
+	atomic.AddInt64(&s.messages, 1)
+}
+
+// retireLoop gives up one receive loop unless it is the last one and returns the number of loops left
+func (s *runStats) retireLoop() (int64, bool) {
+	return s.retireLoopAbove(1)
+}
+
+// retireLoopAbove gives up one receive loop unless min loops are left and returns the number of loops left
+func (s *runStats) retireLoopAbove(min int64) (int64, bool) {
+	for {
+		n := atomic.LoadInt64(&s.loops)
+		if n <= min {
+			return n, false
+		}
+		if atomic.CompareAndSwapInt64(&s.loops, n, n-1) {
+			return n - 1, true
+		}
+	}
+}
+
+// addLoopBelow adds a receive loop unless max loops are running and returns the number of loops
+func (s *runStats) addLoopBelow(max int64) (int64, bool) {
+	for {
+		n := atomic.LoadInt64(&s.loops)
+		if n >= max {
+			return n, false
+		}
+		if atomic.CompareAndSwapInt64(&s.loops, n, n+1) {
+			return n + 1, true
+		}
+	}
+}
+
+func (s *runStats) summary() Summary {
+	return Summary{
+		Sessions:    atomic.LoadInt64(&s.sessions),
+		Messages:    atomic.LoadInt64(&s.messages),
+		Duration:    time.Since(s.start),
+		Concurrency: atomic.LoadInt64(&s.loops),
+		HoldTime:    time.Duration(atomic.LoadInt64(&s.holdTime)),
+		MaxHoldTime: time.Duration(atomic.LoadInt64(&s.maxHoldTime)),
+	}
+}
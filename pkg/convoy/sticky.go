@@ -0,0 +1,31 @@
+package convoy
+
+import (
+	"time"
+)
+
+// stickyRetryBackoff is the wait before a sticky session is accepted again after it was lost
+const stickyRetryBackoff = 5 * time.Second
+
+// WithStickySession dedicates the convoy to the session sessionID for its lifetime, e.g. for actor style processing
+// where one process owns the state of an entity. The convoy accepts only this session and keeps it when it is idle
+// instead of moving on, so messages are handled as soon as they arrive. If the session is lost, e.g. because the
+// connection dropped, the convoy accepts it again. The session is released only on shutdown.
+//
+// A sticky session holds a receiver link and its session lock for as long as the convoy runs, and the lock is renewed
+// in the background even while no messages arrive. No other receiver can process the session meanwhile, so a sticky
+// convoy should be the only consumer of its session. It cannot be combined with concurrent sessions or sharding.
+func WithStickySession(sessionID string) Option {
+	return func(c *Convoy) {
+		c.stickySession = sessionID
+	}
+}
+
+// sessionFilter returns the session the convoy accepts, or nil to accept the next available session
+func (c *Convoy) sessionFilter() *string {
+	if c.stickySession == "" {
+		return nil
+	}
+	id := c.stickySession
+	return &id
+}
@@ -0,0 +1,58 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// ReceiveStrategy determines how many messages of a session are fetched from the broker ahead of their handling.
+// Either way messages are handled strictly in session order and each message is settled before the next one is
+// handled, so ordering and at-least-once delivery are the same for both strategies.
+type ReceiveStrategy struct {
+	window uint32
+}
+
+// ReceiveOneByOne fetches the next message of the session only once the current one is settled. It holds no locked
+// messages beyond the one being handled and is the default.
+var ReceiveOneByOne = ReceiveStrategy{window: 1}
+
+// ReceiveWindow fetches up to k messages of the session ahead, so the next message is usually at hand when its
+// predecessor is settled. Prefetched messages are locked with the session; their locks stay valid since the session
+// lock is renewed while the session is processed.
+func ReceiveWindow(k int) ReceiveStrategy {
+	return ReceiveStrategy{window: uint32(k)}
+}
+
+// WithReceiveStrategy selects how session messages are fetched from the broker
+func WithReceiveStrategy(s ReceiveStrategy) Option {
+	return func(c *Convoy) {
+		c.strategy = s
+	}
+}
+
+// String describes the strategy for logging
+func (s ReceiveStrategy) String() string {
+	if s.window <= 1 {
+		return "one by one"
+	}
+	return fmt.Sprintf("window of %d", s.window)
+}
+
+// prefetchQueue creates the session receivers of a queue with a prefetch count, which the SDK does not apply to
+// session receivers built from the queue itself
+type prefetchQueue struct {
+	*servicebus.Queue
+	prefetch uint32
+}
+
+// NewReceiver creates a receiver that holds up to the prefetch count of messages
+func (q prefetchQueue) NewReceiver(ctx context.Context, opts ...servicebus.ReceiverOption) (*servicebus.Receiver, error) {
+	return q.Queue.NewReceiver(ctx, append(opts, servicebus.ReceiverWithPrefetchCount(q.prefetch))...)
+}
+
+// newSession creates the receiver for the next available session following the receive strategy
+func (c *Convoy) newSession() sessionReceiver {
+	return c.source.newSession(c.sessionFilter(), c.strategy.window)
+}
@@ -0,0 +1,46 @@
+package convoy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestReceiveStrategies(t *testing.T) {
+	tests := []struct {
+		strategy    ReceiveStrategy
+		maxInFlight int
+	}{
+		{strategy: ReceiveOneByOne, maxInFlight: 1},
+		{strategy: ReceiveWindow(3), maxInFlight: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy.String(), func(t *testing.T) {
+			broker := newFakeBroker()
+			broker.add("a", "1", "2", "3", "4", "5")
+			broker.add("b", "1", "2")
+
+			var handled []string
+			c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+				handled = append(handled, msg.ID)
+				return nil
+			}, WithReceiveStrategy(tt.strategy))
+
+			if _, err := c.RunOnce(context.Background()); err != nil {
+				t.Fatalf("RunOnce: %v", err)
+			}
+			if want := []string{"a-1", "a-2", "a-3", "a-4", "a-5", "b-1", "b-2"}; !reflect.DeepEqual(handled, want) {
+				t.Errorf("handled %v, want %v", handled, want)
+			}
+			if n := broker.remaining(); n != 0 {
+				t.Errorf("%d messages left on the broker", n)
+			}
+			if broker.maxInFlight != tt.maxInFlight {
+				t.Errorf("up to %d messages in flight, want %d", broker.maxInFlight, tt.maxInFlight)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package convoy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// StreamHandlerFunc processes a single session message whose body is passed as a reader, so that large payloads can be
+// parsed incrementally, e.g. with a json.Decoder, rather than as a whole. The returned error settles the message like
+// that of a HandlerFunc.
+type StreamHandlerFunc func(ctx context.Context, body io.Reader, msg *servicebus.Message) error
+
+// WithStreamHandler handles messages with fn in place of the HandlerFunc passed to New, which must then be nil. The
+// SDK receives every message body in full before it is delivered, so the reader wraps msg.Data without copying it:
+// this saves the copies a handler would make to parse the body, not the memory of the body itself.
+func WithStreamHandler(fn StreamHandlerFunc) Option {
+	return func(c *Convoy) {
+		c.streamHandler = fn
+	}
+}
+
+// validateStreamHandler turns the stream handler, if set, into the handler of the convoy
+func (c *Convoy) validateStreamHandler() error {
+	if c.streamHandler == nil {
+		return nil
+	}
+	if c.handler != nil {
+		return errors.New("a stream handler cannot be combined with a handler")
+	}
+
+	fn := c.streamHandler
+	c.handler = func(ctx context.Context, msg *servicebus.Message) error {
+		return fn(ctx, bytes.NewReader(msg.Data), msg)
+	}
+	return nil
+}
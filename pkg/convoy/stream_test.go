@@ -0,0 +1,71 @@
+package convoy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// largePayload returns a JSON stream of n records of about a hundred bytes each
+func largePayload(n int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, `{"line":%d,"sku":"SKU-%08d","note":"%064d"}`+"\n", i, i, i)
+	}
+	return buf.Bytes()
+}
+
+func TestStreamHandlerReadsLargePayload(t *testing.T) {
+	const records = 50000
+	payload := largePayload(records)
+	broker := newFakeBroker()
+	msgs := broker.add("a", "")
+	msgs[0].Data = payload
+
+	var decoded int
+	var digest [sha256.Size]byte
+	c := newTestConvoy(t, broker, nil, WithStreamHandler(func(_ context.Context, body io.Reader, msg *servicebus.Message) error {
+		h := sha256.New()
+		dec := json.NewDecoder(io.TeeReader(body, h))
+		for {
+			var line struct{ Line int }
+			if err := dec.Decode(&line); err == io.EOF {
+				break
+			} else if err != nil {
+				return &ErrDeadLetter{Reason: "Invalid", Description: err.Error()}
+			}
+			if line.Line != decoded {
+				return fmt.Errorf("record %d out of order at %d", line.Line, decoded)
+			}
+			decoded++
+		}
+		copy(digest[:], h.Sum(nil))
+		return nil
+	}))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if decoded != records {
+		t.Errorf("decoded %d records of a %d byte payload, want %d", decoded, len(payload), records)
+	}
+	if digest != sha256.Sum256(payload) {
+		t.Error("streamed body differs from the payload")
+	}
+	if got := outcomesOf(broker); len(got) != 1 || got[0] != OutcomeCompleted {
+		t.Errorf("outcomes %v, want the message completed", got)
+	}
+}
+
+func TestStreamHandlerExcludesHandler(t *testing.T) {
+	stream := func(context.Context, io.Reader, *servicebus.Message) error { return nil }
+	if _, err := newConvoy(nopHandler, []Option{WithStreamHandler(stream)}); err == nil {
+		t.Error("newConvoy with a handler and a stream handler succeeded")
+	}
+}
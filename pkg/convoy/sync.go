@@ -0,0 +1,21 @@
+package convoy
+
+import "time"
+
+// withSynchronousMode is for tests only. It removes the background watchdog and lock renewal, and with them all real
+// timers of a session, so a test drives the convoy one step at a time: StepSessionHandler.Handle processes exactly one
+// message per call and Convoy.checkSession performs exactly one watchdog check at the time it is given. Combined with
+// withClock the idleness seen by those checks is under the control of the test as well.
+func withSynchronousMode() Option {
+	return func(c *Convoy) {
+		c.synchronous = true
+	}
+}
+
+// withClock is for tests only. It replaces the monotonic clock the watchdog measures idleness with by now, which
+// returns the time elapsed since an arbitrary origin.
+func withClock(now func() time.Duration) Option {
+	return func(c *Convoy) {
+		c.clock = now
+	}
+}
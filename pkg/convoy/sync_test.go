@@ -0,0 +1,237 @@
+package convoy
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// fakeClock is a monotonic clock that only moves when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Duration
+}
+
+func (c *fakeClock) read() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	c.mu.Unlock()
+}
+
+// syncHarness drives a session of a fakeBroker through a convoy in synchronous mode, one message or watchdog check
+// at a time
+type syncHarness struct {
+	t      *testing.T
+	broker *fakeBroker
+	convoy *Convoy
+	clock  *fakeClock
+	sess   *StepSessionHandler
+	ms     *fakeSession
+}
+
+// newSyncHarness accepts the first session of broker with an idle timeout of a minute
+func newSyncHarness(t *testing.T, broker *fakeBroker, handler HandlerFunc, opts ...Option) *syncHarness {
+	t.Helper()
+	h := &syncHarness{t: t, broker: broker, clock: &fakeClock{}}
+	defaults := []Option{withSynchronousMode(), withClock(h.clock.read), WithIdleTimeout(time.Minute)}
+	h.convoy = newTestConvoy(t, broker, handler, append(defaults, opts...)...)
+
+	id, ok := broker.lock(nil)
+	if !ok {
+		t.Fatal("no session to accept")
+	}
+	h.ms = &fakeSession{id: id, lockedUntil: time.Now().Add(time.Minute), closed: make(chan struct{})}
+	h.sess = &StepSessionHandler{convoy: h.convoy, stats: &runStats{}}
+	if err := h.sess.start(h.ms); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(h.sess.End)
+	return h
+}
+
+// step hands the next message of the session to the convoy
+func (h *syncHarness) step() error {
+	h.t.Helper()
+	msg, ok := h.broker.next(h.ms.id)
+	if !ok {
+		h.t.Fatal("no message left to step")
+	}
+	return h.sess.Handle(context.Background(), msg)
+}
+
+// check advances the clock by d and performs a watchdog check, reporting whether it closed the session
+func (h *syncHarness) check(d time.Duration) bool {
+	h.clock.advance(d)
+	return h.convoy.checkSession(h.sess, time.Now())
+}
+
+func TestSynchronousWatchdogExpiresIdleSession(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	h := newSyncHarness(t, broker, nopHandler)
+
+	if err := h.step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	if h.check(59 * time.Second) {
+		t.Fatal("session closed before its idle timeout")
+	}
+
+	// Handling a message restarts the idle timeout
+	if err := h.step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	if h.check(59 * time.Second) {
+		t.Fatal("session closed before its idle timeout after handling a message")
+	}
+	if !h.check(2 * time.Second) {
+		t.Fatal("idle session not closed after its idle timeout")
+	}
+	if !h.ms.isClosed() || !h.sess.isReleased() {
+		t.Error("expired session not released")
+	}
+	if n := len(broker.settlements()); n != 2 {
+		t.Errorf("%d messages settled, want 2", n)
+	}
+}
+
+func TestSynchronousWatchdogCancelsHungHandler(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1")
+	started := make(chan struct{})
+	h := newSyncHarness(t, broker, func(ctx context.Context, _ *servicebus.Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- h.step()
+	}()
+	<-started
+
+	if h.check(30 * time.Second) {
+		t.Fatal("session closed while its handler runs")
+	}
+	if h.check(31 * time.Second) {
+		t.Fatal("session closed instead of cancelling its hung handler")
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	settled := broker.settlements()
+	if len(settled) != 1 || settled[0].outcome != OutcomeAbandoned {
+		t.Errorf("settlements %+v, want the message of the hung handler abandoned", settled)
+	}
+	if h.ms.isClosed() {
+		t.Error("session of a hung handler closed")
+	}
+}
+
+func TestSynchronousWatchdogIdleClockStartsAtAccept(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1")
+	clock := &fakeClock{}
+	c := newTestConvoy(t, broker, nopHandler, withSynchronousMode(), withClock(clock.read), WithIdleTimeout(time.Minute))
+
+	// The receive loop creates its handler before a session is accepted, which may take longer than the idle timeout
+	sess := &StepSessionHandler{convoy: c, stats: &runStats{}}
+	clock.advance(2 * time.Minute)
+	id, ok := broker.lock(nil)
+	if !ok {
+		t.Fatal("no session to accept")
+	}
+	ms := &fakeSession{id: id, lockedUntil: time.Now().Add(time.Minute), closed: make(chan struct{})}
+	if err := sess.start(ms); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	t.Cleanup(sess.End)
+
+	if c.checkSession(sess, time.Now()) {
+		t.Fatal("session expired right after a slow accept")
+	}
+	msg, _ := broker.next(id)
+	if err := sess.Handle(context.Background(), msg); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if n := len(broker.settlements()); n != 1 {
+		t.Errorf("%d messages settled, want 1", n)
+	}
+	clock.advance(61 * time.Second)
+	if !c.checkSession(sess, time.Now()) {
+		t.Error("idle session not closed after its idle timeout")
+	}
+}
+
+func TestSynchronousWatchdogIgnoresWallClockJumps(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1")
+	h := newSyncHarness(t, broker, nopHandler)
+	if err := h.step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+
+	// The wall clock jumps an hour backwards, then two hours forwards, while only seconds pass on the monotonic clock
+	h.clock.advance(time.Second)
+	if h.convoy.checkSession(h.sess, time.Now().Add(-time.Hour)) {
+		t.Fatal("session closed after the wall clock jumped backwards")
+	}
+	h.clock.advance(time.Second)
+	if h.convoy.checkSession(h.sess, time.Now().Add(time.Hour)) {
+		t.Fatal("session closed after the wall clock jumped forwards")
+	}
+
+	// Expiry still follows the monotonic clock, whatever the wall clock reads
+	h.clock.advance(time.Minute)
+	if !h.convoy.checkSession(h.sess, time.Now().Add(-time.Hour)) {
+		t.Error("idle session not closed after its idle timeout on the monotonic clock")
+	}
+}
+
+func TestSynchronousWatchdogChecksAtIdleDeadline(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+	h := newSyncHarness(t, broker, nopHandler)
+	timeout := h.convoy.idleTimeout + h.convoy.expiryGrace
+
+	if err := h.step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	// Activity is stamped with the wall clock, so the deadline may lag the injected clock by the real time elapsed
+	within := func(next, want time.Duration) bool {
+		return next <= want && next > want-time.Second
+	}
+	h.clock.advance(20 * time.Second)
+	if next := h.convoy.nextCheck(h.sess); !within(next, timeout-20*time.Second) {
+		t.Fatalf("next check in %v, want %v", next, timeout-20*time.Second)
+	}
+
+	// Handling a message moves the deadline
+	if err := h.step(); err != nil {
+		t.Fatalf("step: %v", err)
+	}
+	next := h.convoy.nextCheck(h.sess)
+	if !within(next, timeout) {
+		t.Fatalf("next check in %v after handling a message, want %v", next, timeout)
+	}
+	if h.check(next - time.Nanosecond) {
+		t.Fatal("session closed before its idle deadline")
+	}
+	if next := h.convoy.nextCheck(h.sess); next != time.Nanosecond {
+		t.Fatalf("next check in %v, want 1ns", next)
+	}
+	if !h.check(time.Nanosecond) {
+		t.Error("session not closed at its idle deadline")
+	}
+}
@@ -0,0 +1,36 @@
+package convoy
+
+import (
+	"context"
+	"time"
+)
+
+// serverBusyBackoff is the wait Service Bus recommends after a server-busy error before the next attempt
+const serverBusyBackoff = 10 * time.Second
+
+// WithOnThrottle calls fn whenever the broker throttles the convoy with a server-busy error, before the convoy backs
+// off for retryAfter, e.g. to alert or to slow down the producers of the queue. fn should return quickly.
+func WithOnThrottle(fn func(retryAfter time.Duration)) Option {
+	return func(c *Convoy) {
+		c.onThrottle = fn
+	}
+}
+
+// throttled reports a server-busy error and waits for the recommended backoff unless ctx is done first
+func (c *Convoy) throttled(ctx context.Context, err error) error {
+	c.logf("🐢 Namespace is busy, backing off for %v: %v", serverBusyBackoff, err)
+	c.metrics.IncCounter(metricThrottled)
+	if c.onThrottle != nil {
+		c.notifyThrottle(serverBusyBackoff)
+	}
+
+	return sleepCtx(ctx, serverBusyBackoff)
+}
+
+// notifyThrottle calls the throttle callback, recovering a panic which is logged by recoverPanic, so that it cannot
+// take down the receive loop
+func (c *Convoy) notifyThrottle(retryAfter time.Duration) (err error) {
+	defer c.recoverPanic(ErrHandlerPanic, "notifying throttling", &err)
+	c.onThrottle(retryAfter)
+	return nil
+}
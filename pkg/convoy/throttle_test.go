@@ -0,0 +1,25 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThrottledRecoversCallbackPanic(t *testing.T) {
+	var retryAfter time.Duration
+	c := newTestConvoy(t, newFakeBroker(), nopHandler, WithOnThrottle(func(d time.Duration) {
+		retryAfter = d
+		panic("alerting failed")
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.throttled(ctx, errors.New("server busy")); !errors.Is(err, context.Canceled) {
+		t.Errorf("throttled = %v, want %v", err, context.Canceled)
+	}
+	if retryAfter != serverBusyBackoff {
+		t.Errorf("callback called with %v, want %v", retryAfter, serverBusyBackoff)
+	}
+}
@@ -0,0 +1,33 @@
+package convoy
+
+import (
+	"context"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// traceProperties are the application properties carrying the trace context of a message: Diagnostic-Id as set by the
+// Azure SDKs, and traceparent and tracestate as defined by W3C Trace Context
+var traceProperties = []string{"Diagnostic-Id", "traceparent", "tracestate"}
+
+// attemptCorrelationProperty records the correlation value under which the convoy logged the failed processing attempt
+const attemptCorrelationProperty = "ConvoyCorrelationID"
+
+// deadLetterProperties returns the properties the convoy sets on a message it dead-letters, so that the dead-lettered
+// message can be tied back to the failed processing attempt in a tracing backend: the trace context of the message,
+// the correlation value of the attempt's log lines and the worker identity. The trace context is normally retained by
+// the broker anyway; it is set explicitly so that it survives forwarding as well. The correlation ID of the message is
+// left unchanged and the failure reason is carried as DeadLetterReason and DeadLetterErrorDescription.
+func (c *Convoy) deadLetterProperties(ctx context.Context, msg *servicebus.Message) map[string]interface{} {
+	props := make(map[string]interface{}, len(traceProperties)+2)
+	for _, name := range traceProperties {
+		if v, ok := msg.UserProperties[name]; ok && v != nil {
+			props[name] = v
+		}
+	}
+	if id := CorrelationID(ctx); id != "" {
+		props[attemptCorrelationProperty] = id
+	}
+	c.stampWorker(props)
+	return props
+}
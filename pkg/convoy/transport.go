@@ -0,0 +1,31 @@
+package convoy
+
+import (
+	"crypto/tls"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// The convoy supports the two transports offered by Service Bus:
+//
+//   - AMQP over TCP on port 5671, the default.
+//   - AMQP over WebSockets on port 443, for networks that only allow outbound HTTPS. Enable it with WithWebSocket.
+//
+// Both transports use TLS and work with the shared access signature carried by the connection string. A custom TLS
+// config only applies to AMQP over TCP: the WebSocket connection negotiates TLS with the default HTTPS settings.
+
+// WithWebSocket connects to the namespace with AMQP over WebSockets on port 443 instead of AMQP on port 5671
+func WithWebSocket() Option {
+	return func(c *Convoy) {
+		c.webSocket = true
+		c.namespaceOptions = append(c.namespaceOptions, servicebus.NamespaceWithWebSocket())
+	}
+}
+
+// WithTLSConfig uses config for the TLS connection to the namespace, e.g. to trust a corporate proxy's root CA
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Convoy) {
+		c.tlsConfig = config
+		c.namespaceOptions = append(c.namespaceOptions, servicebus.NamespaceWithTLSConfig(config))
+	}
+}
@@ -0,0 +1,61 @@
+package convoy
+
+import (
+	"context"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+type expiresKey struct{}
+
+// WithSkipExpiredMessages completes messages whose time-to-live has elapsed by the time they are handled without
+// calling the handler, since a backlogged convoy would otherwise keep working on stale messages, and bounds the context
+// of the handler by the expiry of its message. A handler that is still running when its message expires is cancelled
+// and its message abandoned, and then skipped on redelivery.
+func WithSkipExpiredMessages() Option {
+	return func(c *Convoy) {
+		c.skipExpired = true
+	}
+}
+
+// RemainingTTL returns the time left until the message handled with ctx expires. It reports false if the message has
+// no time-to-live or ctx is not the context of a handler.
+func RemainingTTL(ctx context.Context) (time.Duration, bool) {
+	at, ok := ctx.Value(expiresKey{}).(time.Time)
+	if !ok {
+		return 0, false
+	}
+	return time.Until(at), true
+}
+
+// expiresAt returns when msg expires, derived from its enqueued time and time-to-live
+func expiresAt(msg *servicebus.Message) (time.Time, bool) {
+	if msg.TTL == nil || *msg.TTL <= 0 || msg.SystemProperties == nil || msg.SystemProperties.EnqueuedTime == nil {
+		return time.Time{}, false
+	}
+	return msg.SystemProperties.EnqueuedTime.Add(*msg.TTL), true
+}
+
+// withExpiry stores the expiry of msg in ctx, and bounds ctx by it if WithSkipExpiredMessages is set
+func (c *Convoy) withExpiry(ctx context.Context, msg *servicebus.Message) (context.Context, context.CancelFunc) {
+	at, ok := expiresAt(msg)
+	if !ok {
+		return ctx, func() {}
+	}
+
+	ctx = context.WithValue(ctx, expiresKey{}, at)
+	if !c.skipExpired {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, at)
+}
+
+// isExpired reports whether msg is to be skipped because its time-to-live has elapsed
+func (c *Convoy) isExpired(msg *servicebus.Message) bool {
+	if !c.skipExpired {
+		return false
+	}
+	at, ok := expiresAt(msg)
+	return ok && !time.Now().Before(at)
+}
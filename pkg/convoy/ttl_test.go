@@ -0,0 +1,65 @@
+package convoy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// withTTL gives msg a time-to-live of ttl from its enqueued time
+func withTTL(msg *servicebus.Message, enqueued time.Time, ttl time.Duration) {
+	msg.SystemProperties.EnqueuedTime = &enqueued
+	msg.TTL = &ttl
+}
+
+func TestSkipExpiredMessages(t *testing.T) {
+	for _, skip := range []bool{true, false} {
+		broker := newFakeBroker()
+		msgs := broker.add("a", "expired", "fresh", "forever")
+		withTTL(msgs[0], time.Now().Add(-2*time.Hour), time.Hour)
+		withTTL(msgs[1], time.Now(), time.Hour)
+
+		var handled []string
+		var opts []Option
+		if skip {
+			opts = append(opts, WithSkipExpiredMessages())
+		}
+		c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+			handled = append(handled, string(msg.Data))
+			remaining, ok := RemainingTTL(ctx)
+			deadline, bounded := ctx.Deadline()
+			switch string(msg.Data) {
+			case "fresh":
+				if !ok || remaining <= 59*time.Minute || remaining > time.Hour {
+					t.Errorf("remaining TTL of fresh message %v, %v, want about an hour", remaining, ok)
+				}
+				if bounded != skip || (skip && !deadline.Equal(msg.SystemProperties.EnqueuedTime.Add(time.Hour))) {
+					t.Errorf("deadline of fresh message %v, %v, want its expiry if expired messages are skipped", deadline, bounded)
+				}
+			case "forever":
+				if ok {
+					t.Errorf("remaining TTL of message without TTL %v, want none", remaining)
+				}
+			}
+			return nil
+		}, opts...)
+
+		if _, err := c.RunOnce(context.Background()); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		want := []string{"expired", "fresh", "forever"}
+		if skip {
+			want = want[1:]
+		}
+		if !reflect.DeepEqual(handled, want) {
+			t.Errorf("skip expired %v: handled %v, want %v", skip, handled, want)
+		}
+		wantOutcomes := []Outcome{OutcomeCompleted, OutcomeCompleted, OutcomeCompleted}
+		if got := outcomesOf(broker); !reflect.DeepEqual(got, wantOutcomes) {
+			t.Errorf("skip expired %v: outcomes %v, want %v", skip, got, wantOutcomes)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// Tx is a transaction the handler runs in, see WithTransactional. *sql.Tx satisfies it.
+type Tx interface {
+	Commit() error
+	Rollback() error
+}
+
+type txKey struct{}
+
+// WithTransactional runs every handler invocation in a transaction started by begin, which the handler retrieves with
+// TxFrom to do its work, e.g. database writes and outbox records. If the handler's result completes the message, the
+// transaction is committed and then the message is completed; if committing fails the message is abandoned. Any other
+// result rolls the transaction back before the message is settled accordingly, and a failing begin abandons the
+// message without calling the handler.
+//
+// Committing and completing are two steps, so delivery stays at least once: if the process dies or completing fails
+// after the commit, the message is delivered again although its work was committed. Record the message ID in the same
+// transaction, e.g. in an outbox or a table of processed IDs, and have the handler skip messages it finds there to make
+// the effect exactly once.
+func WithTransactional(begin func(ctx context.Context) (Tx, error)) Option {
+	return func(c *Convoy) {
+		c.beginTx = begin
+	}
+}
+
+// TxFrom returns the transaction the handler with ctx runs in, or nil outside of a transactional handler
+func TxFrom(ctx context.Context) Tx {
+	tx, _ := ctx.Value(txKey{}).(Tx)
+	return tx
+}
+
+// runTransactional runs the handler in a transaction if WithTransactional is set
+func (sh *StepSessionHandler) runTransactional(ctx context.Context, msg *servicebus.Message) error {
+	begin := sh.convoy.beginTx
+	if begin == nil {
+		return sh.runHandler(ctx, msg)
+	}
+
+	tx, err := begin(ctx)
+	if err != nil {
+		sh.convoy.msgLogf(ctx, "❗ Failed to begin transaction, abandoning message: %v", err)
+		return fmt.Errorf("%w: begin transaction: %v", ErrAbandon, err)
+	}
+
+	err = sh.runHandler(context.WithValue(ctx, txKey{}, tx), msg)
+	if settlementFor(err).outcome != OutcomeCompleted {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			sh.convoy.msgLogf(ctx, "❗ Failed to roll back transaction: %v", rbErr)
+		}
+		return err
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		sh.convoy.msgLogf(ctx, "❗ Failed to commit transaction, abandoning message: %v", commitErr)
+		return fmt.Errorf("%w: commit transaction: %v", ErrAbandon, commitErr)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package convoy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+// fakeTx records the end of a transaction in a shared log
+type fakeTx struct {
+	log       *[]string
+	commitErr error
+}
+
+func (tx *fakeTx) Commit() error {
+	*tx.log = append(*tx.log, "commit")
+	return tx.commitErr
+}
+
+func (tx *fakeTx) Rollback() error {
+	*tx.log = append(*tx.log, "rollback")
+	return nil
+}
+
+func TestTransactionalSettlesAfterTransaction(t *testing.T) {
+	broker := newFakeBroker()
+	broker.add("a", "1", "2")
+
+	var steps []string
+	broker.settleErr = func(msg *servicebus.Message) error {
+		steps = append(steps, "settle "+msg.ID)
+		return nil
+	}
+	commitErrs := []error{nil, nil, errors.New("serialization failure"), nil}
+	c := newTestConvoy(t, broker, func(ctx context.Context, msg *servicebus.Message) error {
+		if TxFrom(ctx) == nil {
+			t.Errorf("handler of %s runs without a transaction", msg.ID)
+		}
+		steps = append(steps, "handle "+msg.ID)
+		if msg.ID == "a-1" && msg.DeliveryCount == 1 {
+			return ErrAbandon
+		}
+		return nil
+	}, WithTransactional(func(context.Context) (Tx, error) {
+		tx := &fakeTx{log: &steps}
+		tx.commitErr, commitErrs = commitErrs[0], commitErrs[1:]
+		return tx, nil
+	}))
+
+	if _, err := c.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// a-1 is rolled back and abandoned, then committed and completed; the first commit of a-2 fails and abandons it
+	want := []string{
+		"handle a-1", "rollback", "settle a-1",
+		"handle a-1", "commit", "settle a-1",
+		"handle a-2", "commit", "settle a-2",
+		"handle a-2", "commit", "settle a-2",
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps %v, want %v", steps, want)
+	}
+	wantOutcomes := []Outcome{OutcomeAbandoned, OutcomeCompleted, OutcomeAbandoned, OutcomeCompleted}
+	if got := outcomesOf(broker); !reflect.DeepEqual(got, wantOutcomes) {
+		t.Errorf("outcomes %v, want %v", got, wantOutcomes)
+	}
+	if tx := TxFrom(context.Background()); tx != nil {
+		t.Errorf("transaction %v outside of a handler, want nil", tx)
+	}
+}
@@ -0,0 +1,34 @@
+package convoy
+
+import (
+	"context"
+)
+
+// WithMaxMessagesPerSessionVisit releases a session after n of its messages were settled in one visit, so that a
+// session with a large backlog cannot starve the others: it returns to the pool of available sessions and is picked up
+// again later, by this or another receiver, resuming with its next message in order. Together with several receivers
+// this processes large convoys round-robin. Each visit costs an accept, so n should be well above the few messages a
+// typical session holds. See WithMaxSessionHoldTime to limit the time rather than the number of messages per visit.
+func WithMaxMessagesPerSessionVisit(n int) Option {
+	return func(c *Convoy) {
+		c.maxPerVisit = n
+	}
+}
+
+// visitExhausted releases the session once it has used up its messages per visit and reports whether it did
+func (sh *StepSessionHandler) visitExhausted(ctx context.Context) bool {
+	if sh.convoy.maxPerVisit <= 0 {
+		return false
+	}
+
+	sh.RLock()
+	processed := sh.processed
+	sh.RUnlock()
+	if processed < sh.convoy.maxPerVisit {
+		return false
+	}
+
+	sh.convoy.msgLogf(ctx, "🔁 Processed %d messages in this visit. Releasing session to give other sessions a turn.", processed)
+	sh.release()
+	return true
+}
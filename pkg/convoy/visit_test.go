@@ -0,0 +1,42 @@
+package convoy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-service-bus-go"
+)
+
+func TestMaxMessagesPerSessionVisitTakesTurns(t *testing.T) {
+	broker := newFakeBroker()
+	var bodies []string
+	for i := 1; i <= 6; i++ {
+		bodies = append(bodies, fmt.Sprint(i))
+	}
+	broker.add("a", bodies...)
+	broker.add("b", bodies...)
+
+	var handled []string
+	c := newTestConvoy(t, broker, func(_ context.Context, msg *servicebus.Message) error {
+		handled = append(handled, msg.ID)
+		return nil
+	}, WithMaxMessagesPerSessionVisit(2))
+
+	summary, err := c.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	// Neither session waits for the backlog of the other, and each resumes in order where its last visit ended
+	want := []string{"a-1", "a-2", "b-1", "b-2", "a-3", "a-4", "b-3", "b-4", "a-5", "a-6", "b-5", "b-6"}
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+	if summary.Sessions != 6 {
+		t.Errorf("%d sessions, want 3 visits of each", summary.Sessions)
+	}
+	if n := broker.remaining(); n != 0 {
+		t.Errorf("%d messages left on the broker", n)
+	}
+}
@@ -0,0 +1,63 @@
+package convoy
+
+import (
+	"expvar"
+	"os"
+)
+
+// MetricLabeler is implemented by Metrics that can attach a constant label to every series they report. The convoy
+// uses it to label its metrics with the worker identity.
+type MetricLabeler interface {
+	WithLabel(name, value string) Metrics
+}
+
+// WithWorkerID sets the identity of this convoy instance, e.g. a pod name, to tell replicas apart. It prefixes every
+// log line, labels the metrics if they implement MetricLabeler, is published as worker_id with WithExpvar and can be
+// stamped onto failed messages with WithWorkerIDProperty. It defaults to the hostname; an empty id disables it.
+func WithWorkerID(id string) Option {
+	return func(c *Convoy) {
+		c.workerID = id
+	}
+}
+
+// WithWorkerIDProperty stamps the worker identity as application property name onto messages the convoy dead-letters,
+// forwards with WithDeadLetterForward or moves to the retry queue, so downstream tooling knows which worker failed
+// them. Abandoned messages cannot be stamped, as the SDK does not modify properties on abandon.
+func WithWorkerIDProperty(name string) Option {
+	return func(c *Convoy) {
+		c.workerProperty = name
+	}
+}
+
+// defaultWorkerID returns the hostname, or an empty id if it cannot be determined
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// labelWorker labels the metrics of the convoy with the worker identity if they implement MetricLabeler
+func (c *Convoy) labelWorker() {
+	if l, ok := c.metrics.(MetricLabeler); ok && c.workerID != "" {
+		c.metrics = l.WithLabel("worker", c.workerID)
+	}
+}
+
+// publishWorker publishes the worker identity as worker_id in the expvar map
+func (m *expvarMetrics) publishWorker(id string) {
+	if id == "" {
+		return
+	}
+	s := new(expvar.String)
+	s.Set(id)
+	m.vars.Set("worker_id", s)
+}
+
+// stampWorker sets the worker property on the properties of a failed message if WithWorkerIDProperty is set
+func (c *Convoy) stampWorker(props map[string]interface{}) {
+	if c.workerProperty != "" && c.workerID != "" {
+		props[c.workerProperty] = c.workerID
+	}
+}